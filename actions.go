@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"golang.org/x/crypto/ssh"
+)
+
+// showError logs err and reports it in the main window.
+func (x *xinStatus) showError(err error) {
+	log.Println(err)
+	fyne.Do(func() {
+		dialog.ShowError(err, x.window)
+	})
+}
+
+// wake sends a Wake-on-LAN packet to s. It is called from UI callbacks
+// and marks s as waking before anything else happens, so a double click
+// only sends one packet.
+func (x *xinStatus) wake(s *Status) {
+	if s.mac == nil {
+		x.showError(fmt.Errorf("can't wake %s: no valid MAC configured", s.PrettyName()))
+		return
+	}
+	if s.waking {
+		return
+	}
+	s.waking = true
+
+	go func() {
+		if err := x.sendWake(s); err != nil {
+			s.waking = false
+			x.showError(fmt.Errorf("can't wake %s: %w", s.PrettyName(), err))
+			return
+		}
+		x.awaitWake(s)
+	}()
+}
+
+func (x *xinStatus) sendWake(s *Status) error {
+	log.Printf("sending wake to %s", s.Host)
+	pass, err := parseSecureOn(s.WakePassword)
+	if err != nil {
+		return err
+	}
+
+	opts := wakeOptions{
+		broadcast: s.WakeBroadcast,
+		port:      s.WakePort,
+		iface:     s.WakeInterface,
+	}
+
+	pkt, err := newMagicPacket(s.mac, pass)
+	if err != nil {
+		return err
+	}
+
+	sender, err := x.wakeSender(s)
+	if err != nil {
+		return err
+	}
+
+	return sender.sendWake(pkt, opts)
+}
+
+// wakeSender picks how magic packets for s get delivered.
+func (x *xinStatus) wakeSender(s *Status) (wakeSender, error) {
+	if s.WakeRelay == "" {
+		return udpSender{}, nil
+	}
+
+	relay := x.findHost(s.WakeRelay)
+	if relay == nil {
+		return nil, fmt.Errorf("unknown wake relay %q", s.WakeRelay)
+	}
+	log.Printf("relaying wake for %s through %s", s.Host, relay.Host)
+
+	return relaySender{
+		relay: relay,
+		cmd:   x.config.WakeRelayCmd,
+	}, nil
+}
+
+func (x *xinStatus) findHost(name string) *Status {
+	for _, s := range x.config.Statuses {
+		if s.Name == name || s.Host == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (x *xinStatus) setWaking(s *Status, waking bool, subtitle, role string) {
+	s.waking = waking
+	fyne.Do(func() {
+		if waking {
+			s.wakeProgress.Show()
+		} else {
+			s.wakeProgress.Hide()
+		}
+	})
+	s.setSubtitle(subtitle, role)
+}
+
+// awaitWake polls the SSH port of s with backoff after a wake request and
+// reports if it doesn't answer within the configured timeout.
+func (x *xinStatus) awaitWake(s *Status) {
+	timeout := 3 * time.Minute
+	if x.config.WakeTimeout != "" {
+		d, err := time.ParseDuration(x.config.WakeTimeout)
+		if err != nil {
+			log.Println(err)
+		} else {
+			timeout = d
+		}
+	}
+
+	start := time.Now()
+	delay := 2 * time.Second
+	x.setWaking(s, true, "waking…", roleOffline)
+	for time.Since(start) < timeout {
+		conn, err := net.DialTimeout("tcp", s.addr(), 2*time.Second)
+		if err == nil {
+			conn.Close()
+			took := time.Since(start).Round(time.Second)
+			x.setWaking(s, false, fmt.Sprintf("awake after %s", took), roleHealthy)
+			x.Log(fmt.Sprintf("%s came up %s after wake", s.PrettyName(), took))
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > 30*time.Second {
+			delay = 30 * time.Second
+		}
+		x.setWaking(s, true, fmt.Sprintf("waking… %s", time.Since(start).Round(time.Second)), roleOffline)
+	}
+
+	x.setWaking(s, false, fmt.Sprintf("didn't wake within %s", timeout), roleUnhealthy)
+	x.showError(fmt.Errorf("%s didn't come up within %s of being woken", s.PrettyName(), timeout))
+}
+
+// runJob runs fn against s and records it in the job log.
+func (x *xinStatus) runJob(s *Status, action string, auto bool, fn func(*Status) error) {
+	x.runJobResult(s, action, auto, func(s *Status) (string, error) {
+		return "", fn(s)
+	})
+}
+
+// runJobResult is runJob for actions that have something to report.
+func (x *xinStatus) runJobResult(s *Status, action string, auto bool, fn func(*Status) (string, error)) {
+	j := x.jobs.start(s.PrettyName(), action, auto)
+	result, err := fn(s)
+	x.jobs.finish(j, result, err)
+}
+
+// skipJob records in the job log that action wasn't run on s, and why.
+func (x *xinStatus) skipJob(s *Status, action, reason string) {
+	j := x.jobs.start(s.PrettyName(), action, false)
+	x.jobs.finish(j, "skipped: "+reason, nil)
+}
+
+// update runs "xin update" on s.
+func (x *xinStatus) update(s *Status) error {
+	s.updating = true
+	err := s.RunCmd("xin update", x)
+	s.updating = false
+	if err != nil {
+		log.Println(err)
+	}
+	s.SshClose()
+	return err
+}
+
+// confirmReboot asks before running "xin reboot" on s.
+func (x *xinStatus) confirmReboot(s *Status) {
+	fyne.Do(func() {
+		x.window.Show()
+		cnf := dialog.NewConfirm("Confirmation", fmt.Sprintf("Are you sure you want to reboot %q?", s.Host), func(doit bool) {
+			if doit {
+				go x.runJob(s, actionReboot, false, x.rebootJob)
+			}
+		}, x.window)
+		cnf.SetDismissText("Cancel")
+		cnf.SetConfirmText("Ok")
+		cnf.Show()
+	})
+}
+
+// reboot runs "xin reboot" on s and starts watching for it to come back.
+func (x *xinStatus) reboot(s *Status) (*rebootWatch, error) {
+	if err := x.claimReboot(s); err != nil {
+		return nil, err
+	}
+	defer x.releaseReboot(s)
+
+	since := time.Now()
+	if err := s.RunCmd("xin reboot", x); !rebootSent(err) {
+		return nil, fmt.Errorf("%s: xin reboot: %w", s.PrettyName(), err)
+	}
+
+	w := x.watchReboot(s, since)
+	s.SshClose()
+	s.setSubtitle(fmt.Sprintf("rebooting, expected back by %s", w.deadline.Format("15:04")), roleReboot)
+	return w, nil
+}
+
+// rebootSent reports whether "xin reboot" got through given the error it
+// returned. The connection often drops before it reports back, which
+// counts as sent.
+func rebootSent(err error) bool {
+	var missing *ssh.ExitMissingError
+	return err == nil || errors.As(err, &missing) || errors.Is(err, io.EOF)
+}
+
+func (x *xinStatus) rebootJob(s *Status) error {
+	_, err := x.reboot(s)
+	return err
+}
+
+// openTerminal starts the configured terminal with an SSH session to s.
+func (x *xinStatus) openTerminal(s *Status) {
+	term := x.config.Terminal
+	if term == "" {
+		term = "xterm -e"
+	}
+	args := strings.Fields(term)
+	args = append(args, "ssh", "-p", strconv.Itoa(int(s.Port)), "root@"+s.Host)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		log.Println(err)
+		return
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+}
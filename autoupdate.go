@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+const defaultAutoUpdateAfter = 24 * time.Hour
+
+// autoUpdateAfter is how long s may lag behind upstream before its policy
+// updates it.
+func (x *xinStatus) autoUpdateAfter(s *Status) time.Duration {
+	for _, v := range []string{s.AutoUpdateAfter, x.config.AutoUpdateAfter} {
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("%s: invalid auto update threshold %q: %s", s.PrettyName(), v, err)
+			continue
+		}
+		return d
+	}
+	return defaultAutoUpdateAfter
+}
+
+// trackStaleness notes when s first fell behind upstream.
+func (s *Status) trackStaleness() {
+	if s.state != stateBehind {
+		s.staleSince = time.Time{}
+		return
+	}
+	if s.staleSince.IsZero() {
+		s.staleSince = time.Now()
+	}
+}
+
+// runAutoUpdates updates hosts that have opted in and have been behind for
+// longer than their threshold, as long as they're inside their maintenance
+// window (if they have one), automatic actions haven't been paused and the
+// host hasn't been pinned to a generation by hand.
+// Hosts on a revision we can't place (stateUnknownRev) are never updated,
+// since that might move them backwards.
+func (x *xinStatus) runAutoUpdates() {
+	if x.paused() {
+		return
+	}
+
+	now := time.Now()
+	for _, s := range x.config.Statuses {
+		if !s.AutoUpdate || !s.clientEstablished || s.updating {
+			continue
+		}
+		if s.state != stateBehind || s.staleSince.IsZero() {
+			continue
+		}
+		if x.pins.reason(s.Host) != "" {
+			continue
+		}
+		if now.Sub(s.staleSince) < x.autoUpdateAfter(s) {
+			continue
+		}
+		if w := x.hostWindow(s); w != nil && !w.contains(now) {
+			continue
+		}
+
+		// Restart the clock so a failed update isn't retried every pass.
+		s.staleSince = now
+
+		s := s
+		go x.runJob(s, actionUpdate, true, x.update)
+	}
+}
+
+// pauseFile holds the state of the "Pause automatic actions" switch so it
+// survives a restart. auto_paused in the config only sets the initial
+// state for when the switch has never been touched.
+var pauseFile = path.Clean(path.Join(os.Getenv("HOME"), ".xin-paused.json"))
+
+type pauseState struct {
+	AutoPaused bool `json:"auto_paused"`
+}
+
+// loadAutoPaused returns the saved switch state, or def if there is none.
+func loadAutoPaused(def bool) bool {
+	data, err := os.ReadFile(pauseFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return def
+	}
+	var st pauseState
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Println(err)
+		return def
+	}
+	return st.AutoPaused
+}
+
+// paused reports whether automatic actions are paused. The switch is
+// flipped from the UI while the poll loop reads it.
+func (x *xinStatus) paused() bool {
+	x.pauseMu.Lock()
+	defer x.pauseMu.Unlock()
+	return x.autoPaused
+}
+
+// setAutoPaused flips the kill-switch for automatic updates and scheduled
+// actions and saves it.
+func (x *xinStatus) setAutoPaused(paused bool) {
+	x.pauseMu.Lock()
+	x.autoPaused = paused
+	x.pauseMu.Unlock()
+
+	data, err := json.Marshal(pauseState{AutoPaused: paused})
+	if err == nil {
+		err = os.WriteFile(pauseFile, data, 0600)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	if paused {
+		x.Log("automatic actions paused")
+	} else {
+		x.Log("automatic actions resumed")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fleetSummary is the single source of truth for fleet-wide counts. The
+// progress bar, tray icon and anything else that reports on the fleet as a
+// whole derive from it rather than keeping their own tallies.
+type fleetSummary struct {
+	Total       int
+	Reachable   int
+	Current     int
+	Stale       int
+	Unknown     int
+	NeedsReboot int
+	Rebooting   int
+}
+
+// Offline is the number of configured hosts we couldn't reach.
+func (f fleetSummary) Offline() int {
+	return f.Total - f.Reachable
+}
+
+func summarize(statuses []*Status) fleetSummary {
+	f := fleetSummary{
+		Total: len(statuses),
+	}
+	for _, s := range statuses {
+		// A rebooting host is usually unreachable, so count it first.
+		if s.rebootWatch != nil {
+			f.Rebooting++
+		}
+
+		if !s.clientEstablished || s.state == stateOffline {
+			continue
+		}
+		f.Reachable++
+
+		if s.report.NeedsRestart {
+			f.NeedsReboot++
+		}
+
+		switch s.state {
+		case stateCurrent:
+			f.Current++
+		case stateBehind, stateAhead, stateDiverged:
+			f.Stale++
+		default:
+			f.Unknown++
+		}
+	}
+	return f
+}
+
+// describe renders the summary as a single line, e.g.
+// "7/9 current, 1 offline, 2 need reboot, 1 rebooting, upstream 1a2b3c4d".
+func (f fleetSummary) describe(upstream string) string {
+	parts := []string{fmt.Sprintf("%d/%d current", f.Current, f.Total)}
+	if f.Stale > 0 {
+		parts = append(parts, fmt.Sprintf("%d stale", f.Stale))
+	}
+	if f.Unknown > 0 {
+		parts = append(parts, fmt.Sprintf("%d unknown", f.Unknown))
+	}
+	if n := f.Offline(); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d offline", n))
+	}
+	switch f.NeedsReboot {
+	case 0:
+	case 1:
+		parts = append(parts, "1 needs reboot")
+	default:
+		parts = append(parts, fmt.Sprintf("%d need reboot", f.NeedsReboot))
+	}
+	if f.Rebooting > 0 {
+		parts = append(parts, fmt.Sprintf("%d rebooting", f.Rebooting))
+	}
+	if upstream != "" {
+		parts = append(parts, fmt.Sprintf("upstream %.8s", upstream))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,149 @@
+package main
+
+import "testing"
+
+func host(state hostState, online bool) *Status {
+	return &Status{state: state, clientEstablished: online}
+}
+
+func needsReboot(s *Status) *Status {
+	s.report.NeedsRestart = true
+	return s
+}
+
+func rebooting(s *Status) *Status {
+	s.rebootWatch = &rebootWatch{}
+	return s
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []*Status
+		want  fleetSummary
+		text  string
+	}{
+		{
+			name: "empty",
+			want: fleetSummary{},
+			text: "0/0 current",
+		},
+		{
+			name: "all current",
+			hosts: []*Status{
+				host(stateCurrent, true),
+				host(stateCurrent, true),
+			},
+			want: fleetSummary{Total: 2, Reachable: 2, Current: 2},
+			text: "2/2 current",
+		},
+		{
+			name: "offline",
+			hosts: []*Status{
+				host(stateCurrent, true),
+				host(stateOffline, false),
+			},
+			want: fleetSummary{Total: 2, Reachable: 1, Current: 1},
+			text: "1/2 current, 1 offline",
+		},
+		{
+			name: "behind ahead and diverged are stale",
+			hosts: []*Status{
+				host(stateBehind, true),
+				host(stateAhead, true),
+				host(stateDiverged, true),
+				host(stateCurrent, true),
+			},
+			want: fleetSummary{Total: 4, Reachable: 4, Current: 1, Stale: 3},
+			text: "1/4 current, 3 stale",
+		},
+		{
+			name: "dirty and unknown revisions are unknown",
+			hosts: []*Status{
+				host(stateDirty, true),
+				host(stateUnknownRev, true),
+			},
+			want: fleetSummary{Total: 2, Reachable: 2, Unknown: 2},
+			text: "0/2 current, 2 unknown",
+		},
+		{
+			name: "needs reboot",
+			hosts: []*Status{
+				needsReboot(host(stateCurrent, true)),
+				needsReboot(host(stateBehind, true)),
+				host(stateCurrent, true),
+			},
+			want: fleetSummary{Total: 3, Reachable: 3, Current: 2, Stale: 1, NeedsReboot: 2},
+			text: "2/3 current, 1 stale, 2 need reboot",
+		},
+		{
+			name: "one needs reboot",
+			hosts: []*Status{
+				needsReboot(host(stateCurrent, true)),
+			},
+			want: fleetSummary{Total: 1, Reachable: 1, Current: 1, NeedsReboot: 1},
+			text: "1/1 current, 1 needs reboot",
+		},
+		{
+			name: "offline hosts don't report needing a reboot",
+			hosts: []*Status{
+				needsReboot(host(stateOffline, false)),
+			},
+			want: fleetSummary{Total: 1},
+			text: "0/1 current, 1 offline",
+		},
+		{
+			name: "rebooting hosts count while unreachable",
+			hosts: []*Status{
+				rebooting(host(stateOffline, false)),
+				host(stateCurrent, true),
+			},
+			want: fleetSummary{Total: 2, Reachable: 1, Current: 1, Rebooting: 1},
+			text: "1/2 current, 1 offline, 1 rebooting",
+		},
+		{
+			// Hosts that dropped off keep their last state. They used to
+			// be counted against the up-to-date total as well as being
+			// offline, which could push the progress bar below zero.
+			name: "more stale hosts than reachable",
+			hosts: []*Status{
+				host(stateBehind, true),
+				{state: stateBehind},
+				{state: stateBehind},
+				{state: stateDiverged},
+			},
+			want: fleetSummary{Total: 4, Reachable: 1, Stale: 1},
+			text: "0/4 current, 1 stale, 3 offline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.hosts)
+			if got != tt.want {
+				t.Errorf("summarize() = %+v, want %+v", got, tt.want)
+			}
+			if text := got.describe(""); text != tt.text {
+				t.Errorf("describe() = %q, want %q", text, tt.text)
+			}
+
+			// The progress bar shows Current out of Reachable.
+			if got.Current < 0 || got.Current > got.Reachable {
+				t.Errorf("progress %d of %d is out of range", got.Current, got.Reachable)
+			}
+			if got.Stale > got.Reachable || got.Offline() < 0 {
+				t.Errorf("stale %d / offline %d inconsistent with %d reachable",
+					got.Stale, got.Offline(), got.Reachable)
+			}
+		})
+	}
+}
+
+func TestDescribeUpstream(t *testing.T) {
+	f := fleetSummary{Total: 1, Reachable: 1, Current: 1}
+	got := f.describe("1a2b3c4d5e6f")
+	want := "1/1 current, upstream 1a2b3c4d"
+	if got != want {
+		t.Errorf("describe() = %q, want %q", got, want)
+	}
+}
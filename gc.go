@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	actionGC       = "gc"
+	actionOptimise = "optimise"
+
+	optimiseCmd = "nix-store --optimise"
+)
+
+// Age options for garbage collection, mapped to nix-collect-garbage flags.
+const (
+	gcKeepGenerations = "Keep all generations"
+	gcOlderThan7d     = "Generations older than 7 days"
+	gcOlderThan30d    = "Generations older than 30 days"
+	gcAllGenerations  = "All old generations"
+)
+
+var errCleaning = errors.New("already cleaning the store")
+
+var gcAges = []string{gcKeepGenerations, gcOlderThan7d, gcOlderThan30d, gcAllGenerations}
+
+func gcCmd(age string) string {
+	switch age {
+	case gcOlderThan7d:
+		return "nix-collect-garbage --delete-older-than 7d"
+	case gcOlderThan30d:
+		return "nix-collect-garbage --delete-older-than 30d"
+	case gcAllGenerations:
+		return "nix-collect-garbage -d"
+	}
+	return "nix-collect-garbage"
+}
+
+// freedRE matches both "1234 store paths deleted, 56.78 MiB freed" from
+// nix-collect-garbage and "12.34 MiB freed by hard-linking 567 files" from
+// nix-store --optimise.
+var freedRE = regexp.MustCompile(`([\d.]+ [KMGT]?i?B) freed`)
+
+// reclaimed sums up what the output of a GC or optimise run says it freed.
+func reclaimed(out string) string {
+	var freed []string
+	for _, m := range freedRE.FindAllStringSubmatch(out, -1) {
+		freed = append(freed, m[1])
+	}
+	if len(freed) == 0 {
+		return ""
+	}
+	var total float64
+	for _, f := range freed {
+		total += sizeBytes(f)
+	}
+	return fmt.Sprintf("%s freed", formatBytes(uint64(total)))
+}
+
+// collectGarbage runs nix-collect-garbage on s.
+func (x *xinStatus) collectGarbage(s *Status, age string) (string, error) {
+	return x.cleanStore(s, gcCmd(age))
+}
+
+// optimiseStore hard-links identical files in the store of s.
+func (x *xinStatus) optimiseStore(s *Status) (string, error) {
+	return x.cleanStore(s, optimiseCmd)
+}
+
+// cleanStore runs cmd on s unless a GC or optimise is already running
+// there, and reports what it freed.
+func (x *xinStatus) cleanStore(s *Status, cmd string) (string, error) {
+	if !atomic.CompareAndSwapInt32(&s.cleaning, 0, 1) {
+		return "", fmt.Errorf("%s: %w", s.PrettyName(), errCleaning)
+	}
+	defer atomic.StoreInt32(&s.cleaning, 0)
+
+	out, err := s.Exec(cmd, nil)
+	if err != nil {
+		return strings.TrimSpace(string(out)), err
+	}
+	return reclaimed(string(out)), nil
+}
+
+const (
+	cleanGC       = "Collect garbage"
+	cleanOptimise = "Optimise store"
+	cleanBoth     = "Collect garbage and optimise"
+)
+
+// showCleanDialog asks how to clean the stores of hosts and then does it,
+// one host at a time so a fleet-wide run doesn't hit every disk at once.
+func (x *xinStatus) showCleanDialog(title string, hosts []*Status) {
+	what := widget.NewSelect([]string{cleanGC, cleanOptimise, cleanBoth}, nil)
+	what.SetSelected(cleanGC)
+	age := widget.NewSelect(gcAges, nil)
+	age.SetSelected(gcOlderThan30d)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Action", what),
+		widget.NewFormItem("Delete", age),
+	}
+
+	dialog.ShowForm(title, "Run", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		gc := what.Selected != cleanOptimise
+		optimise := what.Selected != cleanGC
+		ageSel := age.Selected
+		go func() {
+			for _, s := range hosts {
+				if !s.clientEstablished {
+					if gc {
+						x.skipJob(s, actionGC, "not connected")
+					}
+					if optimise {
+						x.skipJob(s, actionOptimise, "not connected")
+					}
+					continue
+				}
+				if gc {
+					x.runJobResult(s, actionGC, false, func(s *Status) (string, error) {
+						return x.collectGarbage(s, ageSel)
+					})
+				}
+				if optimise {
+					x.runJobResult(s, actionOptimise, false, x.optimiseStore)
+				}
+			}
+		}()
+	}, x.window)
+}
+
+// cleanAll offers to clean every host. Hosts that aren't connected when
+// their turn comes are recorded in the job log as skipped.
+func (x *xinStatus) cleanAll() {
+	connected := false
+	for _, s := range x.config.Statuses {
+		if s.clientEstablished {
+			connected = true
+			break
+		}
+	}
+	if !connected {
+		dialog.ShowInformation("Clean stores", "No hosts are connected.", x.window)
+		return
+	}
+	x.showCleanDialog("Clean all stores", x.config.Statuses)
+}
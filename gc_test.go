@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGCCmd(t *testing.T) {
+	tests := []struct {
+		age  string
+		want string
+	}{
+		{gcKeepGenerations, "nix-collect-garbage"},
+		{gcOlderThan7d, "nix-collect-garbage --delete-older-than 7d"},
+		{gcOlderThan30d, "nix-collect-garbage --delete-older-than 30d"},
+		{gcAllGenerations, "nix-collect-garbage -d"},
+		{"", "nix-collect-garbage"},
+	}
+	for _, tt := range tests {
+		if got := gcCmd(tt.age); got != tt.want {
+			t.Errorf("gcCmd(%q) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestReclaimed(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{
+			name: "gc",
+			out: "finding garbage collector roots...\n" +
+				"removing stale link from '/nix/var/nix/gcroots/auto/abc' to '/tmp/result'\n" +
+				"deleting garbage...\n" +
+				"deleting '/nix/store/3ff9c1hy6ah1mrj0dbqpcffdmbpc9xqi-hello-2.12.1'\n" +
+				"deleting unused links...\n" +
+				"note: currently hard linking saves 1.25 GiB\n" +
+				"1234 store paths deleted, 567.89 MiB freed\n",
+			want: "567.9 MiB freed",
+		},
+		{
+			name: "gc with generations",
+			out: "removing old generations of profile /nix/var/nix/profiles/system\n" +
+				"removing profile version 41\n" +
+				"removing profile version 42\n" +
+				"finding garbage collector roots...\n" +
+				"deleting garbage...\n" +
+				"3 store paths deleted, 2.00 GiB freed\n",
+			want: "2.0 GiB freed",
+		},
+		{
+			name: "nothing to collect",
+			out:  "finding garbage collector roots...\ndeleting garbage...\n0 store paths deleted, 0.00 MiB freed\n",
+			want: "0 B freed",
+		},
+		{
+			name: "optimise",
+			out:  "12.34 MiB freed by hard-linking 567 files\n",
+			want: "12.3 MiB freed",
+		},
+		{
+			name: "gc then optimise",
+			out:  "10 store paths deleted, 1.50 GiB freed\n512.00 MiB freed by hard-linking 99 files\n",
+			want: "2.0 GiB freed",
+		},
+		{
+			name: "kibibytes",
+			out:  "2 store paths deleted, 4.00 KiB freed\n",
+			want: "4.0 KiB freed",
+		},
+		{
+			name: "no summary",
+			out:  "error: cannot delete path '/nix/store/abc' since it is still alive\n",
+			want: "",
+		},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reclaimed(tt.out); got != tt.want {
+				t.Errorf("reclaimed() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanStoreOncePerHost(t *testing.T) {
+	x := &xinStatus{}
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+
+	// A GC is already running on s.
+	s.cleaning = 1
+	if _, err := x.optimiseStore(s); !errors.Is(err, errCleaning) {
+		t.Errorf("optimiseStore() = %v, want %v", err, errCleaning)
+	}
+	if _, err := x.collectGarbage(s, gcOlderThan30d); !errors.Is(err, errCleaning) {
+		t.Errorf("collectGarbage() = %v, want %v", err, errCleaning)
+	}
+	if s.cleaning != 1 {
+		t.Error("a refused run released the running one")
+	}
+}
+
+func TestSkipJob(t *testing.T) {
+	x := &xinStatus{jobs: &jobLog{file: filepath.Join(t.TempDir(), "jobs.log")}}
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+
+	x.skipJob(s, actionGC, "not connected")
+	if x.jobs.len() != 1 {
+		t.Fatalf("%d jobs logged, want 1", x.jobs.len())
+	}
+	j := x.jobs.newest(0)
+	if j.Host != "box" || j.Action != actionGC || j.Result != "skipped: not connected" || j.Finished.IsZero() {
+		t.Errorf("job = %+v", j)
+	}
+}
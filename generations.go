@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	actionSwitchGeneration = "switch generation"
+	actionRollback         = "rollback"
+
+	systemProfile = "/nix/var/nix/profiles/system"
+	activateCmd   = systemProfile + "/bin/switch-to-configuration switch"
+
+	// listGenerationsCmd prefers the JSON listing from newer nixos-rebuild
+	// and otherwise prints "number, mtime, revision" for each generation
+	// followed by the link the profile points at.
+	listGenerationsCmd = `nixos-rebuild list-generations --json 2>/dev/null || {
+for l in ` + systemProfile + `-*-link; do
+	n=${l#` + systemProfile + `-}; n=${n%-link}
+	printf '%s\t%s\t%s\n' "$n" "$(stat -c %Y "$l")" "$("$l/sw/bin/nixos-version" --configuration-revision 2>/dev/null)"
+done
+echo "current $(readlink ` + systemProfile + `)"
+}`
+)
+
+// generation is one entry in a host's system profile.
+type generation struct {
+	Number   int    `json:"generation"`
+	Date     string `json:"date"`
+	Revision string `json:"configurationRevision"`
+	Version  string `json:"nixosVersion"`
+	Current  bool   `json:"current"`
+}
+
+func (g generation) String() string {
+	s := fmt.Sprintf("%d  %s", g.Number, g.Date)
+	if g.Version != "" {
+		s += "  " + g.Version
+	}
+	if g.Revision != "" {
+		s += fmt.Sprintf("  %.8s", g.Revision)
+	}
+	if g.Current {
+		s += "  (current)"
+	}
+	return s
+}
+
+func parseGenerations(out string) ([]generation, error) {
+	out = strings.TrimSpace(out)
+	if strings.HasPrefix(out, "[") {
+		var gens []generation
+		if err := json.Unmarshal([]byte(out), &gens); err != nil {
+			return nil, err
+		}
+		return gens, nil
+	}
+
+	var gens []generation
+	current := ""
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if link := strings.TrimPrefix(line, "current "); link != line {
+			current = strings.TrimSpace(link)
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation %q", fields[0])
+		}
+		g := generation{Number: n}
+		if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			g.Date = time.Unix(secs, 0).Format("2006-01-02 15:04")
+		}
+		if len(fields) > 2 {
+			g.Revision = strings.TrimSpace(fields[2])
+		}
+		gens = append(gens, g)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range gens {
+		if current == fmt.Sprintf("system-%d-link", gens[i].Number) {
+			gens[i].Current = true
+		}
+	}
+	return gens, nil
+}
+
+// listGenerations fetches the system generations of s, newest first.
+func listGenerations(s *Status) ([]generation, error) {
+	out, err := s.Exec(listGenerationsCmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: listing generations: %w", s.PrettyName(), err)
+	}
+	gens, err := parseGenerations(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("%s: listing generations: %w", s.PrettyName(), err)
+	}
+	// The shell glob sorts lexically, so put them in order ourselves.
+	sort.Slice(gens, func(i, j int) bool {
+		return gens[i].Number > gens[j].Number
+	})
+	return gens, nil
+}
+
+// pinList records hosts whose generation was picked by hand. Automatic
+// and scheduled updates leave them alone until the pin is cleared, so a
+// rollback isn't undone by the next update.
+type pinList struct {
+	mu   sync.Mutex
+	file string
+	pins map[string]string
+}
+
+func newPinList() *pinList {
+	return &pinList{
+		file: path.Clean(path.Join(os.Getenv("HOME"), ".xin-pinned.json")),
+		pins: make(map[string]string),
+	}
+}
+
+func (p *pinList) load() error {
+	data, err := os.ReadFile(p.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return json.Unmarshal(data, &p.pins)
+}
+
+// save writes the pins out. The caller must hold p.mu.
+func (p *pinList) save() error {
+	data, err := json.MarshalIndent(p.pins, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.file, data, 0600)
+}
+
+func (p *pinList) pin(host, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pins[host] = reason
+	return p.save()
+}
+
+func (p *pinList) unpin(host string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pins, host)
+	return p.save()
+}
+
+// reason returns why host is pinned, or "" if it isn't.
+func (p *pinList) reason(host string) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pins[host]
+}
+
+// pin holds automatic updates for s after its generation was picked by hand.
+func (x *xinStatus) pin(s *Status, reason string) {
+	reason = fmt.Sprintf("%s on %s", reason, time.Now().Format("Jan 2 15:04"))
+	if err := x.pins.pin(s.Host, reason); err != nil {
+		log.Println(err)
+	}
+	x.Log(fmt.Sprintf("%s: automatic updates held: %s", s.PrettyName(), reason))
+}
+
+func (x *xinStatus) unpin(s *Status) {
+	if err := x.pins.unpin(s.Host); err != nil {
+		log.Println(err)
+	}
+	x.Log(fmt.Sprintf("%s: automatic updates resumed", s.PrettyName()))
+}
+
+// switchGeneration points the system profile of s at generation n and
+// activates it.
+func (x *xinStatus) switchGeneration(s *Status, n int) (string, error) {
+	cmd := fmt.Sprintf("nix-env -p %s --switch-generation %d && %s", systemProfile, n, activateCmd)
+	out, err := s.Exec(cmd, nil)
+	if err != nil {
+		return strings.TrimSpace(string(out)), err
+	}
+	x.pin(s, fmt.Sprintf("switched to generation %d", n))
+	return fmt.Sprintf("now on generation %d", n), nil
+}
+
+// rollback switches s back to the generation before the current one.
+func (x *xinStatus) rollback(s *Status) (string, error) {
+	cmd := fmt.Sprintf("nix-env -p %s --rollback && %s", systemProfile, activateCmd)
+	out, err := s.Exec(cmd, nil)
+	if err != nil {
+		return strings.TrimSpace(string(out)), err
+	}
+	x.pin(s, "rolled back")
+	return "rolled back", nil
+}
+
+// showGenerations opens a window listing the generations of s.
+func (x *xinStatus) showGenerations(s *Status) {
+	var gens []generation
+	selected := -1
+
+	w := fyne.CurrentApp().NewWindow(fmt.Sprintf("%s generations", s.PrettyName()))
+	status := widget.NewLabel("loading…")
+
+	list := widget.NewList(
+		func() int { return len(gens) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(gens[i].String())
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) { selected = i }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	load := func() {
+		go func() {
+			g, err := listGenerations(s)
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(err.Error())
+					return
+				}
+				gens = g
+				selected = -1
+				list.UnselectAll()
+				list.Refresh()
+				status.SetText(fmt.Sprintf("%d generations", len(gens)))
+			})
+		}()
+	}
+
+	confirm := func(question, action string, fn func(*Status) (string, error)) {
+		dialog.ShowConfirm("Confirmation", question, func(doit bool) {
+			if !doit {
+				return
+			}
+			status.SetText("switching…")
+			go func() {
+				x.runJobResult(s, action, false, func(s *Status) (string, error) {
+					result, err := fn(s)
+					if err != nil {
+						x.showError(fmt.Errorf("%s: %s: %w", s.PrettyName(), action, err))
+					}
+					return result, err
+				})
+				load()
+			}()
+		}, w)
+	}
+
+	switchTo := widget.NewButton("Switch to Selected", func() {
+		if selected < 0 || selected >= len(gens) {
+			return
+		}
+		g := gens[selected]
+		if g.Current {
+			return
+		}
+		confirm(fmt.Sprintf("Switch %q to generation %d from %s?", s.Host, g.Number, g.Date),
+			actionSwitchGeneration, func(s *Status) (string, error) {
+				return x.switchGeneration(s, g.Number)
+			})
+	})
+	rollBack := widget.NewButton("Roll Back", func() {
+		confirm(fmt.Sprintf("Roll %q back to its previous generation?", s.Host),
+			actionRollback, x.rollback)
+	})
+	refresh := widget.NewButton("Refresh", load)
+	resume := widget.NewButton("Resume Auto-Update", func() {
+		x.unpin(s)
+		x.updateScheduleNote(s)
+	})
+
+	w.SetContent(container.NewBorder(
+		status,
+		container.NewHBox(refresh, switchTo, rollBack, resume),
+		nil, nil,
+		list,
+	))
+	w.Resize(fyne.NewSize(500, 400))
+	w.Show()
+
+	load()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGenerations(t *testing.T) {
+	fallbackDate := func(secs int64) string {
+		return time.Unix(secs, 0).Format("2006-01-02 15:04")
+	}
+
+	tests := []struct {
+		name string
+		out  string
+		want []generation
+	}{
+		{
+			name: "nixos-rebuild json",
+			out: `[
+  {"generation": 42, "date": "2024-03-01 10:00:00", "nixosVersion": "24.05.20240301.3a9c1c2", "kernelVersion": "6.6.18", "configurationRevision": "1a2b3c4d5e6f", "specialisations": [], "current": true},
+  {"generation": 41, "date": "2024-02-20 09:30:00", "nixosVersion": "24.05.20240219.0e7f9a1", "kernelVersion": "6.6.17", "configurationRevision": "", "specialisations": [], "current": false}
+]`,
+			want: []generation{
+				{Number: 42, Date: "2024-03-01 10:00:00", Version: "24.05.20240301.3a9c1c2", Revision: "1a2b3c4d5e6f", Current: true},
+				{Number: 41, Date: "2024-02-20 09:30:00", Version: "24.05.20240219.0e7f9a1"},
+			},
+		},
+		{
+			name: "profile links",
+			out: "41\t1708421400\t0f0e0d0c0b0a\n" +
+				"42\t1709287200\t1a2b3c4d5e6f\n" +
+				"43\t1709373600\t\n" +
+				"current system-42-link\n",
+			want: []generation{
+				{Number: 41, Date: fallbackDate(1708421400), Revision: "0f0e0d0c0b0a"},
+				{Number: 42, Date: fallbackDate(1709287200), Revision: "1a2b3c4d5e6f", Current: true},
+				{Number: 43, Date: fallbackDate(1709373600)},
+			},
+		},
+		{
+			name: "profile links without a revision column",
+			out:  "7\t1709287200\ncurrent system-7-link\n",
+			want: []generation{
+				{Number: 7, Date: fallbackDate(1709287200), Current: true},
+			},
+		},
+		{
+			name: "empty",
+			out:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGenerations(tt.out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGenerations() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGenerationsInvalid(t *testing.T) {
+	for _, out := range []string{
+		`[{"generation": "forty-two"}]`,
+		"x\t1709287200\t\n",
+	} {
+		if _, err := parseGenerations(out); err == nil {
+			t.Errorf("parseGenerations(%q) succeeded", out)
+		}
+	}
+}
+
+func TestPinList(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pinned.json")
+	p := newPinList()
+	p.file = file
+
+	if err := p.pin("box", "rolled back"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newPinList()
+	loaded.file = file
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.reason("box"); got != "rolled back" {
+		t.Errorf("reason() = %q, want %q", got, "rolled back")
+	}
+
+	if err := loaded.unpin("box"); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.reason("box"); got != "" {
+		t.Errorf("reason() after unpin = %q, want none", got)
+	}
+
+	var none *pinList
+	if got := none.reason("box"); got != "" {
+		t.Errorf("nil reason() = %q", got)
+	}
+}
+
+func TestAutoUpdateSkipsPinned(t *testing.T) {
+	stale := time.Now().Add(-48 * time.Hour)
+	s := &Status{
+		hostConfig:        hostConfig{Host: "box", AutoUpdate: true},
+		clientEstablished: true,
+		state:             stateBehind,
+		staleSince:        stale,
+	}
+	pins := newPinList()
+	pins.file = filepath.Join(t.TempDir(), "pinned.json")
+	if err := pins.pin("box", "rolled back"); err != nil {
+		t.Fatal(err)
+	}
+	x := &xinStatus{pins: pins, config: Config{Statuses: []*Status{s}}}
+
+	x.runAutoUpdates()
+
+	if !s.staleSince.Equal(stale) {
+		t.Error("auto-update started on a pinned host")
+	}
+}
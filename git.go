@@ -0,0 +1,714 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"container/heap"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errObjectNotFound is returned when an object isn't present in the loose
+// object store or any of the packfiles.
+var errObjectNotFound = errors.New("object not found")
+
+type objType int
+
+const (
+	objCommit   objType = 1
+	objTree     objType = 2
+	objBlob     objType = 3
+	objTag      objType = 4
+	objOfsDelta objType = 6
+	objRefDelta objType = 7
+)
+
+// gitRepo reads refs and objects directly from a repository on disk so we
+// don't depend on a git binary being in PATH.
+type gitRepo struct {
+	gitDir    string
+	commonDir string
+
+	mu      sync.Mutex
+	packs   map[string]*gitPack
+	commits map[string]*commit
+}
+
+type gitPack struct {
+	path    string
+	hashes  []byte
+	fanout  [256]uint32
+	offsets []byte
+	large   []byte
+}
+
+func openRepo(dir string) (*gitRepo, error) {
+	gitDir := filepath.Join(dir, ".git")
+	fi, err := os.Stat(gitDir)
+	switch {
+	case err != nil:
+		// Possibly a bare repository.
+		if _, serr := os.Stat(filepath.Join(dir, "objects")); serr != nil {
+			return nil, err
+		}
+		gitDir = dir
+	case !fi.IsDir():
+		// Worktrees and submodules have a ".git" file pointing elsewhere.
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir: ") {
+			return nil, fmt.Errorf("invalid gitdir file %q", gitDir)
+		}
+		gitDir = strings.TrimPrefix(line, "gitdir: ")
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(dir, gitDir)
+		}
+	}
+
+	r := &gitRepo{
+		gitDir:    gitDir,
+		commonDir: gitDir,
+		packs:     make(map[string]*gitPack),
+		commits:   make(map[string]*commit),
+	}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		r.commonDir = filepath.Clean(common)
+	}
+
+	return r, nil
+}
+
+// resolve turns a ref such as HEAD or refs/heads/main into a hash.
+func (r *gitRepo) resolve(ref string) (string, error) {
+	for i := 0; i < 10; i++ {
+		target, err := r.readRef(ref)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(target, "ref: ") {
+			return target, nil
+		}
+		ref = strings.TrimPrefix(target, "ref: ")
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic refs for %q", ref)
+}
+
+func (r *gitRepo) readRef(ref string) (string, error) {
+	dirs := []string{r.commonDir}
+	if ref == "HEAD" || !strings.HasPrefix(ref, "refs/") {
+		dirs = []string{r.gitDir, r.commonDir}
+	}
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %q", ref)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && parts[1] == ref {
+			return parts[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("unknown ref %q", ref)
+}
+
+// readObject returns the type and inflated contents of the object with
+// the given hash.
+func (r *gitRepo) readObject(hash string) (objType, []byte, error) {
+	if len(hash) != 40 {
+		return 0, nil, fmt.Errorf("invalid object name %q", hash)
+	}
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid object name %q", hash)
+	}
+
+	t, data, err := r.readLoose(hash)
+	if err == nil || !errors.Is(err, errObjectNotFound) {
+		return t, data, err
+	}
+
+	// Packs come and go with fetches and repacks, so rescan once before
+	// giving up, or if the pack we found was removed under us.
+search:
+	for _, rescan := range []bool{false, true} {
+		if rescan {
+			if err := r.loadPacks(); err != nil {
+				return 0, nil, err
+			}
+		}
+		for _, p := range r.packList() {
+			if off, ok := p.find(raw); ok {
+				t, data, err := r.readPacked(p, off)
+				if os.IsNotExist(err) && !rescan {
+					continue search
+				}
+				return t, data, err
+			}
+		}
+	}
+
+	return 0, nil, fmt.Errorf("%s: %w", hash, errObjectNotFound)
+}
+
+func (r *gitRepo) readLoose(hash string) (objType, []byte, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "objects", hash[:2], hash[2:]))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, errObjectNotFound
+		}
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return 0, nil, fmt.Errorf("%s: malformed object header", hash)
+	}
+	hdr := strings.SplitN(string(data[:nul]), " ", 2)
+	if len(hdr) != 2 {
+		return 0, nil, fmt.Errorf("%s: malformed object header", hash)
+	}
+
+	var t objType
+	switch hdr[0] {
+	case "commit":
+		t = objCommit
+	case "tree":
+		t = objTree
+	case "blob":
+		t = objBlob
+	case "tag":
+		t = objTag
+	default:
+		return 0, nil, fmt.Errorf("%s: unknown object type %q", hash, hdr[0])
+	}
+
+	return t, data[nul+1:], nil
+}
+
+func (r *gitRepo) packList() []*gitPack {
+	r.mu.Lock()
+	empty := len(r.packs) == 0
+	r.mu.Unlock()
+
+	if empty {
+		_ = r.loadPacks()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var packs []*gitPack
+	for _, p := range r.packs {
+		packs = append(packs, p)
+	}
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].path < packs[j].path
+	})
+	return packs
+}
+
+// loadPacks rescans the pack directory. Packs that are new are opened and
+// ones that have gone away, e.g. after "git gc" or "git repack", are
+// dropped.
+func (r *gitRepo) loadPacks() error {
+	idxs, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	packs := make(map[string]*gitPack)
+	for _, idx := range idxs {
+		p, ok := r.packs[idx]
+		if !ok {
+			p, err = openPackIndex(idx)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := os.Stat(p.path); err != nil {
+			// Still being written, or just removed.
+			continue
+		}
+		packs[idx] = p
+	}
+	r.packs = packs
+
+	return nil
+}
+
+func openPackIndex(idx string) (*gitPack, error) {
+	data, err := os.ReadFile(idx)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) {
+		return nil, fmt.Errorf("%s: unsupported pack index", idx)
+	}
+	if v := binary.BigEndian.Uint32(data[4:8]); v != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index version %d", idx, v)
+	}
+
+	p := &gitPack{
+		path: strings.TrimSuffix(idx, ".idx") + ".pack",
+	}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+	}
+
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(data) < pos+n*(20+4+4) {
+		return nil, fmt.Errorf("%s: truncated pack index", idx)
+	}
+	p.hashes = data[pos : pos+n*20]
+	pos += n * 20
+	pos += n * 4 // CRCs
+	p.offsets = data[pos : pos+n*4]
+	pos += n * 4
+	p.large = data[pos:]
+
+	return p, nil
+}
+
+func (p *gitPack) find(hash []byte) (int64, bool) {
+	lo := 0
+	if hash[0] > 0 {
+		lo = int(p.fanout[hash[0]-1])
+	}
+	hi := int(p.fanout[hash[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i)*20+20], hash) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashes[i*20:i*20+20], hash) {
+		return 0, false
+	}
+
+	off := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if off&0x80000000 != 0 {
+		li := int(off&0x7fffffff) * 8
+		if li+8 > len(p.large) {
+			return 0, false
+		}
+		return int64(binary.BigEndian.Uint64(p.large[li:])), true
+	}
+
+	return int64(off), true
+}
+
+func (r *gitRepo) readPacked(p *gitPack, offset int64) (objType, []byte, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	return r.readPackedAt(f, offset, 0)
+}
+
+func (r *gitRepo) readPackedAt(f *os.File, offset int64, depth int) (objType, []byte, error) {
+	if depth > 50 {
+		return 0, nil, fmt.Errorf("%s: delta chain too deep", f.Name())
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	t := objType((c >> 4) & 7)
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	var baseType objType
+	var base []byte
+	switch t {
+	case objOfsDelta:
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		baseType, base, err = r.readPackedAt(f, offset-rel, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+	case objRefDelta:
+		var ref [20]byte
+		if _, err := io.ReadFull(br, ref[:]); err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = r.readObject(hex.EncodeToString(ref[:]))
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, err
+	}
+
+	if base == nil {
+		return t, data, nil
+	}
+
+	out, err := applyDelta(base, data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return baseType, out, nil
+}
+
+func deltaSize(delta []byte) (int, []byte) {
+	size, shift := 0, uint(0)
+	for len(delta) > 0 {
+		c := delta[0]
+		delta = delta[1:]
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
+
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta := deltaSize(delta)
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	dstSize, delta := deltaSize(delta)
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			if op == 0 || int(op) > len(delta) {
+				return nil, fmt.Errorf("invalid delta insert")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		var off, n int
+		for i := uint(0); i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				off |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if op&(0x10<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				n |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if n == 0 {
+			n = 0x10000
+		}
+		if off+n > len(base) {
+			return nil, fmt.Errorf("delta copy out of range")
+		}
+		out = append(out, base[off:off+n]...)
+	}
+
+	if len(out) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+
+	return out, nil
+}
+
+// readCommit parses the commit object identified by hash.
+func (r *gitRepo) readCommit(hash string) (*commit, error) {
+	r.mu.Lock()
+	c, ok := r.commits[hash]
+	r.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	t, data, err := r.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if t != objCommit {
+		return nil, fmt.Errorf("%s is not a commit", hash)
+	}
+
+	c = &commit{
+		hash: hash,
+	}
+
+	hdr, msg, _ := strings.Cut(string(data), "\n\n")
+	for _, line := range strings.Split(hdr, "\n") {
+		key, val, _ := strings.Cut(line, " ")
+		switch key {
+		case "parent":
+			c.parents = append(c.parents, val)
+		case "author":
+			c.author, _ = parseSignature(val)
+		case "committer":
+			_, c.date = parseSignature(val)
+		}
+	}
+
+	c.message = strings.TrimRight(msg, "\n")
+	c.subject, _, _ = strings.Cut(c.message, "\n")
+
+	r.mu.Lock()
+	r.commits[hash] = c
+	r.mu.Unlock()
+
+	return c, nil
+}
+
+// parseSignature splits "Name <email> 1700000000 +0100" into the identity
+// and the time it refers to.
+func parseSignature(s string) (string, time.Time) {
+	end := strings.LastIndex(s, "> ")
+	if end < 0 {
+		return s, time.Time{}
+	}
+	who := s[:end+1]
+
+	fields := strings.Fields(s[end+2:])
+	if len(fields) != 2 {
+		return who, time.Time{}
+	}
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return who, time.Time{}
+	}
+	ts := time.Unix(secs, 0)
+
+	tz, err := time.Parse("-0700", fields[1])
+	if err == nil {
+		ts = ts.In(tz.Location())
+	}
+
+	return who, ts
+}
+
+// expandRef finds the full name of a possibly abbreviated ref, using the
+// same search order as git rev-parse.
+func (r *gitRepo) expandRef(name string) (string, error) {
+	for _, candidate := range []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	} {
+		if _, err := r.resolve(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown ref %q", name)
+}
+
+// resolveCommit resolves a branch, tag, remote-tracking ref or hash to the
+// commit it points at, peeling annotated tags along the way.
+func (r *gitRepo) resolveCommit(name string) (string, error) {
+	hash := name
+	if _, err := hex.DecodeString(name); err != nil || len(name) != 40 {
+		ref, err := r.expandRef(name)
+		if err != nil {
+			return "", err
+		}
+		if hash, err = r.resolve(ref); err != nil {
+			return "", err
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		t, data, err := r.readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		switch t {
+		case objCommit:
+			return hash, nil
+		case objTag:
+			hdr, _, _ := strings.Cut(string(data), "\n")
+			if !strings.HasPrefix(hdr, "object ") {
+				return "", fmt.Errorf("%s: malformed tag", hash)
+			}
+			hash = strings.TrimPrefix(hdr, "object ")
+		default:
+			return "", fmt.Errorf("%q does not point at a commit", name)
+		}
+	}
+
+	return "", fmt.Errorf("too many levels of tags for %q", name)
+}
+
+const (
+	walkInclude = 1 << iota
+	walkExclude
+)
+
+type walkItem struct {
+	c     *commit
+	flags int
+}
+
+type walkQueue []*walkItem
+
+func (q walkQueue) Len() int            { return len(q) }
+func (q walkQueue) Less(i, j int) bool  { return q[i].c.date.After(q[j].c.date) }
+func (q walkQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *walkQueue) Push(x interface{}) { *q = append(*q, x.(*walkItem)) }
+func (q *walkQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// revList returns the commits reachable from include but not from exclude,
+// newest first, like "git rev-list exclude..include". At most limit commits
+// are returned; truncated reports whether more were left unvisited.
+func (r *gitRepo) revList(include, exclude string, limit int) (commits []*commit, truncated bool, err error) {
+	seen := make(map[string]*walkItem)
+	q := &walkQueue{}
+
+	push := func(hash string, flags int) error {
+		if it, ok := seen[hash]; ok {
+			it.flags |= flags
+			return nil
+		}
+		c, err := r.readCommit(hash)
+		if err != nil {
+			return err
+		}
+		it := &walkItem{c: c, flags: flags}
+		seen[hash] = it
+		heap.Push(q, it)
+		return nil
+	}
+
+	if err := push(exclude, walkExclude); err != nil {
+		return nil, false, err
+	}
+	if err := push(include, walkInclude); err != nil {
+		return nil, false, err
+	}
+
+	for q.Len() > 0 {
+		interesting := false
+		for _, it := range *q {
+			if it.flags&walkExclude == 0 {
+				interesting = true
+				break
+			}
+		}
+		if !interesting {
+			break
+		}
+
+		it := heap.Pop(q).(*walkItem)
+		if it.flags == walkInclude {
+			if len(commits) == limit {
+				return commits, true, nil
+			}
+			commits = append(commits, it.c)
+		}
+		for _, p := range it.c.parents {
+			if err := push(p, it.flags); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return commits, false, nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/repo.git is a bare repository with loose objects, a pack using
+// OFS_DELTA entries (from "git repack"), a pack using REF_DELTA entries
+// (from "git pack-objects" without --delta-base-offset) and packed refs.
+//
+//	1bd7151b sixth (loose)   main, loose-branch (loose refs)
+//	31bd1127 fifth           packed-refs still has main here
+//	4de746d5 feature work    feature, branched from third
+//	04668e5e fourth          origin/main, v1 (annotated)
+//	c72c3c1c third           light
+//	ad131abd second
+//	b38ff321 first
+const (
+	hashSixth   = "1bd7151b494023ad2622c33aef8963fcea7ab4b6"
+	hashFifth   = "31bd1127d93b62e85139e5cd3121c8c6cba5ecec"
+	hashFeature = "4de746d554125650d2a2e224e649e8421f87b8e1"
+	hashFourth  = "04668e5e11719569dcdb52d14b23a62fe8b0d8ac"
+	hashThird   = "c72c3c1c9c35f37c095c209b2bebe2264b0f9222"
+	hashSecond  = "ad131abd20d928fc92982609d928ec84aa704b4b"
+	hashFirst   = "b38ff3219ee68e6f7bba6d13f9dff6feb1d12107"
+	hashTagV1   = "d55b2bab0c813c7ed9e6b3d0fb98925d6ac73196"
+)
+
+func openTestRepo(t *testing.T) *gitRepo {
+	t.Helper()
+	r, err := openRepo(filepath.Join("testdata", "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// copyTestRepo copies the fixture so a test can change it.
+func copyTestRepo(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join("testdata", "repo.git")
+	dst := filepath.Join(t.TempDir(), "repo.git")
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+// objectHash is the name git gives an object of type t with contents data.
+func objectHash(t objType, data []byte) string {
+	names := map[objType]string{objCommit: "commit", objTree: "tree", objBlob: "blob", objTag: "tag"}
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", names[t], len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestReadAllObjects(t *testing.T) {
+	r := openTestRepo(t)
+
+	var hashes []string
+	loose, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "??", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range loose {
+		hashes = append(hashes, filepath.Base(filepath.Dir(f))+filepath.Base(f))
+	}
+	nloose := len(hashes)
+
+	packs := r.packList()
+	if len(packs) != 2 {
+		t.Fatalf("found %d packs, want 2", len(packs))
+	}
+	for _, p := range packs {
+		for i := 0; i < len(p.hashes); i += 20 {
+			hashes = append(hashes, hex.EncodeToString(p.hashes[i:i+20]))
+		}
+	}
+	if nloose == 0 || len(hashes) == nloose {
+		t.Fatalf("fixture has %d loose and %d packed objects", nloose, len(hashes)-nloose)
+	}
+
+	for _, hash := range hashes {
+		typ, data, err := r.readObject(hash)
+		if err != nil {
+			t.Errorf("readObject(%s): %s", hash, err)
+			continue
+		}
+		if got := objectHash(typ, data); got != hash {
+			t.Errorf("readObject(%s) returned object %s", hash, got)
+		}
+	}
+}
+
+func TestReadObjectMissing(t *testing.T) {
+	r := openTestRepo(t)
+	_, _, err := r.readObject(strings.Repeat("0", 40))
+	if err == nil || !strings.Contains(err.Error(), errObjectNotFound.Error()) {
+		t.Errorf("readObject() = %v, want %v", err, errObjectNotFound)
+	}
+	if _, _, err := r.readObject("not a hash"); err == nil {
+		t.Error("readObject() accepted an invalid name")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello, world")
+	// Source 12, target 11: copy "hello" then insert " there".
+	delta := []byte{12, 11, 0x80 | 0x10, 5, 6, ' ', 't', 'h', 'e', 'r', 'e'}
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello there" {
+		t.Errorf("applyDelta() = %q", got)
+	}
+
+	if _, err := applyDelta([]byte("short"), delta); err == nil {
+		t.Error("applyDelta() accepted the wrong base")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := openTestRepo(t)
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"HEAD", hashSixth},
+		{"refs/heads/main", hashSixth}, // the loose ref wins over packed-refs
+		{"refs/heads/loose-branch", hashSixth},
+		{"refs/heads/feature", hashFeature},
+		{"refs/remotes/origin/main", hashFourth},
+		{"refs/tags/v1", hashTagV1},
+	}
+	for _, tt := range tests {
+		got, err := r.resolve(tt.ref)
+		if err != nil {
+			t.Errorf("resolve(%q): %s", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolve(%q) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+
+	if _, err := r.resolve("refs/heads/nope"); err == nil {
+		t.Error("resolve() found a missing ref")
+	}
+}
+
+func TestExpandRef(t *testing.T) {
+	r := openTestRepo(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"HEAD", "HEAD"},
+		{"main", "refs/heads/main"},
+		{"feature", "refs/heads/feature"},
+		{"v1", "refs/tags/v1"},
+		{"heads/feature", "refs/heads/feature"},
+		{"origin/main", "refs/remotes/origin/main"},
+	}
+	for _, tt := range tests {
+		got, err := r.expandRef(tt.name)
+		if err != nil {
+			t.Errorf("expandRef(%q): %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandRef(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := r.expandRef("nope"); err == nil {
+		t.Error("expandRef() found a missing ref")
+	}
+}
+
+func TestResolveCommit(t *testing.T) {
+	r := openTestRepo(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"HEAD", hashSixth},
+		{"main", hashSixth},
+		{"feature", hashFeature},
+		{"v1", hashFourth}, // annotated tags are peeled
+		{"light", hashThird},
+		{"origin/main", hashFourth},
+		{hashSecond, hashSecond},
+	}
+	for _, tt := range tests {
+		got, err := r.resolveCommit(tt.name)
+		if err != nil {
+			t.Errorf("resolveCommit(%q): %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveCommit(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadCommit(t *testing.T) {
+	r := openTestRepo(t)
+	c, err := r.readCommit(hashFeature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.subject != "feature work" || c.author != "Ada <ada@example.org>" {
+		t.Errorf("readCommit() = %q by %q", c.subject, c.author)
+	}
+	if !reflect.DeepEqual(c.parents, []string{hashThird}) {
+		t.Errorf("parents = %v, want %v", c.parents, []string{hashThird})
+	}
+	if c.date.Unix() != 1709000500 {
+		t.Errorf("date = %s", c.date)
+	}
+
+	if _, err := r.readCommit(hashTagV1); err == nil {
+		t.Error("readCommit() accepted a tag")
+	}
+}
+
+func TestRevList(t *testing.T) {
+	r := openTestRepo(t)
+	tests := []struct {
+		name      string
+		include   string
+		exclude   string
+		limit     int
+		want      []string
+		truncated bool
+	}{
+		{"same", hashSixth, hashSixth, 10, nil, false},
+		{"behind", hashSixth, hashThird, 10, []string{hashSixth, hashFifth, hashFourth}, false},
+		{"diverged", hashSixth, hashFeature, 10, []string{hashSixth, hashFifth, hashFourth}, false},
+		{"other side", hashFeature, hashSixth, 10, []string{hashFeature}, false},
+		{"ahead", hashThird, hashSixth, 10, nil, false},
+		{"limited", hashSixth, hashFirst, 2, []string{hashSixth, hashFifth}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, truncated, err := r.revList(tt.include, tt.exclude, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range commits {
+				got = append(got, c.hash)
+			}
+			if !reflect.DeepEqual(got, tt.want) || truncated != tt.truncated {
+				t.Errorf("revList() = %v, %v, want %v, %v", got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestLoadPacksDropsRemoved(t *testing.T) {
+	dir := copyTestRepo(t)
+	r, err := openRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packs := r.packList()
+	if len(packs) != 2 {
+		t.Fatalf("found %d packs, want 2", len(packs))
+	}
+
+	gone := packs[0].path
+	for _, f := range []string{gone, strings.TrimSuffix(gone, ".pack") + ".idx"} {
+		if err := os.Remove(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.loadPacks(); err != nil {
+		t.Fatal(err)
+	}
+	packs = r.packList()
+	if len(packs) != 1 || packs[0].path == gone {
+		t.Errorf("packs after removal = %v", packs)
+	}
+}
+
+func TestReadObjectAfterRepack(t *testing.T) {
+	dir := copyTestRepo(t)
+	r, err := openRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.readCommit(hashFirst); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move every pack to a new name, as a repack would, behind the back
+	// of the already loaded pack list.
+	for _, p := range r.packList() {
+		for _, ext := range []string{".pack", ".idx"} {
+			old := strings.TrimSuffix(p.path, ".pack") + ext
+			renamed := filepath.Join(filepath.Dir(old), "pack-new"+filepath.Base(old)[len("pack-"):])
+			if err := os.Rename(old, renamed); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	typ, data, err := r.readObject(hashSecond)
+	if err != nil {
+		t.Fatalf("readObject() after repack: %s", err)
+	}
+	if objectHash(typ, data) != hashSecond {
+		t.Error("readObject() after repack returned the wrong object")
+	}
+	for _, p := range r.packList() {
+		if !strings.Contains(p.path, "pack-new") {
+			t.Errorf("stale pack %s still listed", p.path)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	failedUnitsCmd = "systemctl --failed --no-legend --plain"
+	healthInterval = time.Minute
+
+	// checkTimeout bounds each custom check so one that hangs can't hold
+	// on to the connection.
+	checkTimeout = 10 * time.Second
+)
+
+type healthState int
+
+const (
+	healthUnknown healthState = iota
+	healthOK
+	healthFailing
+)
+
+func (h healthState) role() string {
+	switch h {
+	case healthOK:
+		return roleHealthy
+	case healthFailing:
+		return roleUnhealthy
+	default:
+		return roleOffline
+	}
+}
+
+// checkConfig is a custom health check: a command run on the host that
+// passes if it exits zero.
+type checkConfig struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// command wraps the check in timeout(1) so it is killed on the host after
+// checkTimeout.
+func (c checkConfig) command() string {
+	return fmt.Sprintf("timeout %d sh -c %s", int(checkTimeout.Seconds()), shellQuote(c.Command))
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type checkResult struct {
+	name   string
+	ok     bool
+	output string
+}
+
+// hostHealth is the result of the last round of health checks on a host.
+type hostHealth struct {
+	checked     time.Time
+	failedUnits []string
+	checks      []checkResult
+	err         error
+}
+
+func (h *hostHealth) state() healthState {
+	if h == nil || h.err != nil {
+		return healthUnknown
+	}
+	if len(h.problems()) > 0 {
+		return healthFailing
+	}
+	return healthOK
+}
+
+func (h *hostHealth) String() string {
+	switch h.state() {
+	case healthOK:
+		return "healthy"
+	case healthFailing:
+		return fmt.Sprintf("%d failing", len(h.problems()))
+	default:
+		return "health unknown"
+	}
+}
+
+// problems lists failed units and checks, one per line.
+func (h *hostHealth) problems() []string {
+	if h == nil {
+		return nil
+	}
+	var p []string
+	for _, u := range h.failedUnits {
+		p = append(p, fmt.Sprintf("unit %s failed", u))
+	}
+	for _, c := range h.checks {
+		if c.ok {
+			continue
+		}
+		if c.output != "" {
+			p = append(p, fmt.Sprintf("check %s failed: %s", c.name, c.output))
+		} else {
+			p = append(p, fmt.Sprintf("check %s failed", c.name))
+		}
+	}
+	return p
+}
+
+// parseFailedUnits returns the unit names from "systemctl --failed
+// --no-legend --plain" output. Some systemd versions still mark each
+// line with a bullet, which is skipped.
+func parseFailedUnits(out string) []string {
+	var units []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "●" || fields[0] == "*") {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units
+}
+
+// checkHealth collects failed units and runs the configured checks on s
+// over its existing connection, at most once per healthInterval. Checks
+// run in the background so a slow one doesn't hold up polling.
+func (x *xinStatus) checkHealth(s *Status) {
+	if s.health != nil && time.Since(s.health.checked) < healthInterval {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&s.healthChecking, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&s.healthChecking, 0)
+		h := runHealthChecks(s)
+		if !s.clientEstablished {
+			return
+		}
+		if prev := s.health; prev == nil || prev.String() != h.String() {
+			x.Log(fmt.Sprintf("%s: %s", s.PrettyName(), h))
+		}
+		s.health = h
+		fyne.Do(func() { s.healthBadge.setHealth(h) })
+	}()
+}
+
+// runHealthChecks does one round of health checks on s.
+func runHealthChecks(s *Status) *hostHealth {
+	h := &hostHealth{checked: time.Now()}
+	out, err := s.Exec(failedUnitsCmd, nil)
+	if err != nil {
+		h.err = fmt.Errorf("%s: %w", failedUnitsCmd, err)
+		log.Printf("%s: %s", s.PrettyName(), h.err)
+	} else {
+		h.failedUnits = parseFailedUnits(string(out))
+	}
+
+	for _, c := range s.Checks {
+		name := c.Name
+		if name == "" {
+			name = c.Command
+		}
+		out, err := s.Exec(c.command(), nil)
+		var exitErr *ssh.ExitError
+		switch {
+		case err == nil:
+			h.checks = append(h.checks, checkResult{name: name, ok: true})
+		case errors.As(err, &exitErr) && exitErr.ExitStatus() == 124:
+			h.checks = append(h.checks, checkResult{name: name, output: fmt.Sprintf("timed out after %s", checkTimeout)})
+		case errors.As(err, &exitErr):
+			h.checks = append(h.checks, checkResult{name: name, output: strings.TrimSpace(string(out))})
+		default:
+			h.err = fmt.Errorf("check %s: %w", name, err)
+			log.Printf("%s: %s", s.PrettyName(), h.err)
+		}
+	}
+	return h
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"true", "timeout 10 sh -c 'true'"},
+		{"curl -sf localhost:8080", "timeout 10 sh -c 'curl -sf localhost:8080'"},
+		{"test \"$(cat /x)\" = 'ok'", `timeout 10 sh -c 'test "$(cat /x)" = '\''ok'\'''`},
+	}
+	for _, tt := range tests {
+		if got := (checkConfig{Command: tt.cmd}).command(); got != tt.want {
+			t.Errorf("command(%q) = %s, want %s", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestParseFailedUnits(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{"none", "", nil},
+		{"none with newline", "\n", nil},
+		{
+			"plain",
+			"nginx.service        loaded failed failed A high performance web server and a reverse proxy server\n" +
+				"backup@daily.service loaded failed failed Daily backup\n",
+			[]string{"nginx.service", "backup@daily.service"},
+		},
+		{
+			"bulleted",
+			"● nginx.service loaded failed failed A high performance web server and a reverse proxy server\n" +
+				"● zfs-scrub.timer loaded failed failed zfs-scrub timer\n",
+			[]string{"nginx.service", "zfs-scrub.timer"},
+		},
+		{
+			"ascii bullets",
+			"* nginx.service loaded failed failed nginx\n",
+			[]string{"nginx.service"},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseFailedUnits(tt.out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseFailedUnits() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHostHealthState(t *testing.T) {
+	tests := []struct {
+		name     string
+		health   *hostHealth
+		state    healthState
+		role     string
+		text     string
+		problems []string
+	}{
+		{
+			name:  "not checked",
+			state: healthUnknown,
+			role:  roleOffline,
+			text:  "health unknown",
+		},
+		{
+			name:   "healthy",
+			health: &hostHealth{checks: []checkResult{{name: "web", ok: true}}},
+			state:  healthOK,
+			role:   roleHealthy,
+			text:   "healthy",
+		},
+		{
+			name: "failing",
+			health: &hostHealth{
+				failedUnits: []string{"nginx.service"},
+				checks: []checkResult{
+					{name: "web", ok: true},
+					{name: "disk", output: "97% used"},
+					{name: "ping"},
+				},
+			},
+			state: healthFailing,
+			role:  roleUnhealthy,
+			text:  "3 failing",
+			problems: []string{
+				"unit nginx.service failed",
+				"check disk failed: 97% used",
+				"check ping failed",
+			},
+		},
+		{
+			name: "error",
+			health: &hostHealth{
+				failedUnits: []string{"nginx.service"},
+				err:         errors.New("session closed"),
+			},
+			state:    healthUnknown,
+			role:     roleOffline,
+			text:     "health unknown",
+			problems: []string{"unit nginx.service failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.health
+			if got := h.state(); got != tt.state {
+				t.Errorf("state() = %v, want %v", got, tt.state)
+			}
+			if got := h.state().role(); got != tt.role {
+				t.Errorf("role() = %q, want %q", got, tt.role)
+			}
+			if got := h.String(); got != tt.text {
+				t.Errorf("String() = %q, want %q", got, tt.text)
+			}
+			if got := h.problems(); !reflect.DeepEqual(got, tt.problems) {
+				t.Errorf("problems() = %q, want %q", got, tt.problems)
+			}
+		})
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"image"
 	"image/color"
 	"image/png"
-	"log"
+	"math"
 )
 
 const width, height = 288, 288
@@ -23,18 +23,6 @@ func parseHexColor(s string) (*color.RGBA, error) {
 	return c, nil
 }
 
-func isEdge(x, y int) bool {
-	if x == 0 || x == width-1 {
-		return true
-	}
-
-	if y == 0 || y == height-1 {
-		return true
-	}
-
-	return false
-}
-
 type myIcon struct {
 	data *image.RGBA
 }
@@ -49,51 +37,150 @@ func (m *myIcon) Content() []byte {
 	return buf.Bytes()
 }
 
-func buildImage(xin *xinStatus) *myIcon {
-	i := &myIcon{}
+// digits is a 3x5 bitmap font used for the tray badge.
+var digits = [10][5]string{
+	{"111", "101", "101", "101", "111"},
+	{"010", "110", "010", "010", "111"},
+	{"111", "001", "111", "100", "111"},
+	{"111", "001", "111", "001", "111"},
+	{"101", "101", "111", "001", "001"},
+	{"111", "100", "111", "001", "111"},
+	{"111", "100", "111", "101", "111"},
+	{"111", "001", "001", "001", "001"},
+	{"111", "101", "111", "101", "111"},
+	{"111", "101", "111", "001", "111"},
+}
 
-	on, err := parseHexColor("#92CAFF")
-	if err != nil {
-		log.Println(err)
+// trayCell is what a single host's segment of the tray icon shows.
+type trayCell struct {
+	state     hostState
+	reboot    bool
+	updating  bool
+	unhealthy bool
+}
+
+func trayCells(xin *xinStatus) []trayCell {
+	var cells []trayCell
+	for _, s := range xin.config.Statuses {
+		c := trayCell{
+			state: s.displayState(),
+		}
+		if s.clientEstablished {
+			c.reboot = s.report.NeedsRestart
+			c.unhealthy = s.health.state() == healthFailing
+		}
+		c.updating = s.updating
+		cells = append(cells, c)
 	}
-	off, err := parseHexColor("#c1c1c1")
-	if err != nil {
-		log.Println(err)
+	return cells
+}
+
+// iconKey changes whenever the rendered tray icon would.
+func iconKey(xin *xinStatus) string {
+	return fmt.Sprint(trayCells(xin), xin.config.TrayBadge, xin.summary,
+		activePalette.currentVariant())
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			img.Set(x, y, c)
+		}
 	}
-	border, err := parseHexColor("#000000")
-	if err != nil {
-		log.Println(err)
+}
+
+func drawBadge(img *image.RGBA, n int, bg, fg color.Color) {
+	if n > 99 {
+		n = 99
 	}
-	rebootColor, err := parseHexColor("#DE3163")
-	if err != nil {
-		log.Println(err)
+	const radius, px = 64, 12
+	cx, cy := width-radius-4, height-radius-4
+
+	for x := cx - radius; x <= cx+radius; x++ {
+		for y := cy - radius; y <= cy+radius; y++ {
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy <= radius*radius {
+				img.Set(x, y, bg)
+			}
+		}
+	}
+
+	str := fmt.Sprint(n)
+	w := len(str)*3*px + (len(str)-1)*px
+	left, top := cx-w/2, cy-5*px/2
+	for i, ch := range str {
+		glyph := digits[ch-'0']
+		ox := left + i*4*px
+		for row, bits := range glyph {
+			for col, bit := range bits {
+				if bit == '1' {
+					fillRect(img, image.Rect(ox+col*px, top+row*px, ox+(col+1)*px, top+(row+1)*px), fg)
+				}
+			}
+		}
 	}
+}
+
+func buildImage(xin *xinStatus) *myIcon {
+	i := &myIcon{}
+
+	on := activePalette.color(roleCurrent)
+	off := activePalette.color(roleOffline)
+	border := activePalette.color(roleBorder)
+	rebootColor := activePalette.color(roleReboot)
+	updatingColor := activePalette.color(roleUpdating)
+	unhealthyColor := activePalette.color(roleUnhealthy)
 
 	i.data = image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(i.data, i.data.Rect, border)
 
-	aliveCount := int(xin.aliveCount())
-	utdCount := int(xin.uptodateCount())
-	gridMark := 1
-	if aliveCount > 0 {
-		gridMark = int(height / aliveCount)
+	cells := trayCells(xin)
+	if len(cells) == 0 {
+		fillRect(i.data, i.data.Rect.Inset(1), off)
+		return i
 	}
 
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			if isEdge(x, y) {
-				i.data.Set(x, y, border)
-			} else {
-				if aliveCount > 0 && y < gridMark*utdCount {
-					if xin.hasReboot {
-						i.data.Set(x, y, rebootColor)
-					} else {
-						i.data.Set(x, y, on)
-					}
-				} else {
-					i.data.Set(x, y, off)
-				}
-			}
+	// Lay hosts out in a grid that's as close to square as we can get.
+	cols := int(math.Ceil(math.Sqrt(float64(len(cells)))))
+	rows := (len(cells) + cols - 1) / cols
+	const gap = 8
+
+	for n, c := range cells {
+		col, row := n%cols, n/cols
+		r := image.Rect(
+			col*width/cols, row*height/rows,
+			(col+1)*width/cols, (row+1)*height/rows,
+		).Inset(gap / 2)
+
+		var fill color.Color
+		switch {
+		case c.updating:
+			fill = updatingColor
+		case c.state == stateOffline:
+			fill = off
+		case c.reboot:
+			fill = rebootColor
+		case c.state == stateCurrent:
+			fill = on
+		default:
+			fill = c.state.color()
+		}
+		fillRect(i.data, r, fill)
+
+		// Health gets its own stripe along the bottom of the segment so
+		// it doesn't hide the revision state.
+		if c.unhealthy {
+			stripe := r
+			stripe.Min.Y = r.Max.Y - r.Dy()/4
+			fillRect(i.data, stripe, unhealthyColor)
 		}
 	}
+
+	if xin.config.TrayBadge {
+		if n := xin.summary.Total - xin.summary.Current; n > 0 {
+			drawBadge(i.data, n, rebootColor, updatingColor)
+		}
+	}
+
 	return i
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// job is a single action run against a host, kept so there's a record of
+// what was done and whether it was us or a policy that did it.
+type job struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Host     string    `json:"host"`
+	Action   string    `json:"action"`
+	Auto     bool      `json:"auto"`
+	Result   string    `json:"result,omitempty"`
+}
+
+func (j *job) String() string {
+	who := "manual"
+	if j.Auto {
+		who = "auto"
+	}
+	s := fmt.Sprintf("%s  %-6s %s on %s", j.Started.Format(time.RFC822), who, j.Action, j.Host)
+	if j.Result != "" {
+		s += ": " + j.Result
+	} else if j.Finished.IsZero() {
+		s += ": running"
+	}
+	return s
+}
+
+// jobLog keeps jobs in memory for the Jobs tab and appends finished ones
+// to a file as JSON lines.
+type jobLog struct {
+	mu   sync.Mutex
+	file string
+	jobs []*job
+	list *widget.List
+}
+
+func newJobLog() *jobLog {
+	return &jobLog{
+		file: path.Clean(path.Join(os.Getenv("HOME"), ".xin-jobs.log")),
+	}
+}
+
+func (l *jobLog) start(host, action string, auto bool) *job {
+	j := &job{
+		Started: time.Now(),
+		Host:    host,
+		Action:  action,
+		Auto:    auto,
+	}
+
+	l.mu.Lock()
+	l.jobs = append(l.jobs, j)
+	l.mu.Unlock()
+
+	log.Println(j)
+	l.refresh()
+	return j
+}
+
+// finish records the outcome of j. A nil err with an empty result is
+// reported as "ok".
+func (l *jobLog) finish(j *job, result string, err error) {
+	l.mu.Lock()
+	j.Finished = time.Now()
+	switch {
+	case err != nil && result != "":
+		j.Result = fmt.Sprintf("%s: %s", err, result)
+	case err != nil:
+		j.Result = err.Error()
+	case result != "":
+		j.Result = result
+	default:
+		j.Result = "ok"
+	}
+	l.mu.Unlock()
+
+	log.Println(j)
+	if err := l.append(j); err != nil {
+		log.Println(err)
+	}
+	l.refresh()
+}
+
+func (l *jobLog) append(j *job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (l *jobLog) refresh() {
+	if l.list != nil {
+		fyne.Do(l.list.Refresh)
+	}
+}
+
+// newest returns the i'th most recent job.
+func (l *jobLog) newest(i int) *job {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.jobs[len(l.jobs)-1-i]
+}
+
+func (l *jobLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.jobs)
+}
+
+func (l *jobLog) widget() *widget.List {
+	l.list = widget.NewList(
+		l.len,
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(l.newest(i).String())
+		},
+	)
+	return l.list
+}
@@ -6,21 +6,24 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
-	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
@@ -34,45 +37,120 @@ var (
 
 type commit struct {
 	hash    string
+	author  string
 	date    time.Time
 	message string
+	subject string
+	parents []string
 }
 
+// errNoCI is returned for CI actions before the CI host has connected, or
+// when none is configured.
+var errNoCI = errors.New("no CI host connected")
+
 type xinStatus struct {
 	tabs            *container.AppTabs
 	cards           []fyne.CanvasObject
 	boundStrings    []binding.ExternalString
 	boundBools      []binding.ExternalBool
 	log             *widget.TextGrid
+	repo            *gitRepo
 	repoCommit      commit
+	trackedRef      string
+	feedHashes      []string
 	config          Config
 	upgradeProgress *widget.ProgressBar
-	hasReboot       bool
+	summary         fleetSummary
+	schedules       *scheduler
+	pins            *pinList
+	jobs            *jobLog
+	packages        *pkgMatrix
+	pauseMu         sync.Mutex
+	autoPaused      bool
+	rollingReboot   bool
+	rebootMu        sync.Mutex
 	window          fyne.Window
 	ci              *Status
 }
 
 type Status struct {
 	card              *widget.Card
+	table             *widget.Table
+	diffView          *diffView
+	closure           map[string]string
+	packagesRev       string
+	subtitle          *canvas.Text
+	badge             *stateBadge
+	healthBadge       *stateBadge
+	health            *hostHealth
+	healthChecking    int32
+	cleaning          int32
+	metrics           *hostMetrics
+	metricsView       *metricsView
 	buttonBox         *fyne.Container
 	commit            commit
+	behind            []*commit
+	behindNote        string
 	client            *ssh.Client
 	sshConn           ssh.Conn
 	conn              net.Conn
 	clientEstablished bool
-	upToDate          bool
+	updating          bool
+	waking            bool
+	scheduleNote      string
+	staleSince        time.Time
+	lastSeen          time.Time
+	rebootWatch       *rebootWatch
+	rebootStarting    bool
+	lost              bool
+	mac               net.HardwareAddr
+	wakeProgress      *widget.ProgressBarInfinite
+	state             hostState
+	extra             map[string]json.RawMessage
+	warnings          []string
+
+	hostConfig
+	report hostReport
+}
 
+// hostConfig is how a host is configured in ~/.xin.json.
+type hostConfig struct {
+	Host              string        `json:"host"`
+	Name              string        `json:"name"`
+	MAC               string        `json:"mac"`
+	Port              int32         `json:"port"`
+	WakeBroadcast     string        `json:"wake_broadcast"`
+	WakePort          int           `json:"wake_port"`
+	WakeInterface     string        `json:"wake_interface"`
+	WakePassword      string        `json:"wake_password"`
+	WakeRelay         string        `json:"wake_relay"`
+	Group             string        `json:"group"`
+	MaintenanceWindow string        `json:"maintenance_window"`
+	AutoUpdate        bool          `json:"auto_update"`
+	AutoUpdateAfter   string        `json:"auto_update_after"`
+	Checks            []checkConfig `json:"checks"`
+}
+
+// hostReport is what a host tells us about itself through "xin status".
+// It is kept apart from hostConfig so nothing the host reports can change
+// how we connect to it.
+type hostReport struct {
 	ConfigurationRevision string `json:"configurationRevision"`
 	NeedsRestart          bool   `json:"needs_restart"`
 	NixosVersion          string `json:"nixosVersion"`
 	NixpkgsRevision       string `json:"nixpkgsRevision"`
 	SystemDiff            string `json:"system_diff"`
-	Host                  string `json:"host"`
-	Name                  string `json:"name"`
-	MAC                   string `json:"mac"`
-	Port                  int32  `json:"port"`
 	Uname                 string `json:"uname_a"`
 	Uptime                string `json:"uptime"`
+
+	// Reported by newer versions of xin.
+	Hostname    string            `json:"hostname"`
+	Host        string            `json:"host"`
+	Port        int32             `json:"port"`
+	Kernel      string            `json:"kernel"`
+	BootTime    string            `json:"boot_time"`
+	FailedUnits []string          `json:"failed_units"`
+	DiskUsage   map[string]string `json:"disk_usage"`
 }
 
 func (s *Status) PrettyName() string {
@@ -82,11 +160,39 @@ func (s *Status) PrettyName() string {
 	return s.Host
 }
 
+// displayState is the state to show for s, taking into account hosts we
+// rebooted that never came back.
+func (s *Status) displayState() hostState {
+	if !s.clientEstablished {
+		if s.lost {
+			return stateNoReturn
+		}
+		return stateOffline
+	}
+	return s.state
+}
+
+// systemDiff decodes the base64 system diff reported by xin.
+func (s *Status) systemDiff() string {
+	text, err := base64.StdEncoding.DecodeString(s.report.SystemDiff)
+	if err != nil {
+		log.Println("decode error:", err)
+		return ""
+	}
+	return string(text)
+}
+
+func (s *Status) addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(int(s.Port)))
+}
+
 func (s *Status) SshClose() error {
-	s.card.Subtitle = "can't connect"
-	fyne.Do(s.card.Refresh)
+	s.setSubtitle("can't connect", roleOffline)
 
 	s.clientEstablished = false
+	if s.client == nil {
+		return nil
+	}
 	return s.client.Close()
 	// s.sshConn.Close()
 
@@ -122,19 +228,22 @@ func makeSshClient(x *xinStatus) (*ssh.ClientConfig, error) {
 
 }
 
+// RunCmd runs cmd on s over a connection of its own, leaving the one the
+// poll loop uses alone.
 func (s *Status) RunCmd(cmd string, x *xinStatus) error {
-	ds := fmt.Sprintf("%s:%d", s.Host, s.Port)
+	ds := s.addr()
 	sshConf, err := makeSshClient(x)
 	if err != nil {
 		return err
 	}
 
-	s.client, err = ssh.Dial("tcp", ds, sshConf)
+	client, err := ssh.Dial("tcp", ds, sshConf)
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
-	session, err := s.client.NewSession()
+	session, err := client.NewSession()
 	if err != nil {
 		return err
 	}
@@ -148,36 +257,55 @@ func (s *Status) RunCmd(cmd string, x *xinStatus) error {
 	return nil
 }
 
-type Config struct {
-	Statuses    []*Status `json:"statuses"`
-	Repo        string    `json:"repo"`
-	PrivKeyPath string    `json:"priv_key_path"`
-	FlakeRSS    string    `json:"flake_rss"`
-	CIHost      string    `json:"ci_host"`
-}
+// Exec runs cmd over the already established connection to s, feeding it
+// stdin if given, and returns the combined output.
+func (s *Status) Exec(cmd string, stdin []byte) ([]byte, error) {
+	if !s.clientEstablished || s.client == nil {
+		return nil, fmt.Errorf("%s: not connected", s.PrettyName())
+	}
 
-func (c *commit) getInfo(repo string) error {
-	msgCmd := exec.Command("git", "log", "--format=%B", "-n", "1", c.hash)
-	msgCmd.Dir = repo
-	msg, err := msgCmd.Output()
+	session, err := s.client.NewSession()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	c.message = trim(msg)
+	defer session.Close()
 
-	dateCmd := exec.Command("git", "log", "--format=%ci", c.hash)
-	dateCmd.Dir = repo
-	d, err := dateCmd.Output()
-	if err != nil {
-		return err
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
 	}
-	dateStr := trim(d)
-	date, err := time.Parse("2006-01-02 15:04:05 -0700", dateStr)
+
+	return session.CombinedOutput(cmd)
+}
+
+type Config struct {
+	Statuses     []*Status     `json:"statuses"`
+	Repo         string        `json:"repo"`
+	Ref          string        `json:"ref"`
+	PrivKeyPath  string        `json:"priv_key_path"`
+	FlakeRSS     string        `json:"flake_rss"`
+	CIHost       string        `json:"ci_host"`
+	TrayBadge    bool          `json:"tray_badge"`
+	Terminal     string        `json:"terminal"`
+	WakeRelayCmd string        `json:"wake_relay_cmd"`
+	WakeTimeout  string        `json:"wake_timeout"`
+	Palette      paletteConfig `json:"palette"`
+
+	MaintenanceWindows map[string]string `json:"maintenance_windows"`
+	AutoUpdateAfter    string            `json:"auto_update_after"`
+	AutoPaused         bool              `json:"auto_paused"`
+	RebootConcurrency  int               `json:"reboot_concurrency"`
+	RebootExclusive    [][]string        `json:"reboot_exclusive"`
+	RebootTimeout      string            `json:"reboot_timeout"`
+	PackageQuery       bool              `json:"package_query"`
+	Thresholds         thresholds        `json:"thresholds"`
+}
+
+func (c *commit) getInfo(repo *gitRepo) error {
+	info, err := repo.readCommit(c.hash)
 	if err != nil {
 		return err
 	}
-
-	c.date = date
+	*c = *info
 
 	return nil
 }
@@ -188,75 +316,149 @@ func NewCommit(c string) *commit {
 	}
 }
 
-func trim(b []byte) string {
-	head := bytes.Split(b, []byte("\n"))
-	if head != nil {
-		return string(head[0])
+func (x *xinStatus) getCommit(c string) (*commit, error) {
+	commit := &commit{
+		hash: c,
+	}
+	if c == "DIRTY" {
+		commit.subject = "uncommitted changes"
+		return commit, nil
 	}
-	return ""
-}
 
-func (x *xinStatus) aliveCount() float64 {
-	alive := 0
-	x.hasReboot = false
-	for _, s := range x.config.Statuses {
-		if s.clientEstablished {
-			alive = alive + 1
-			if s.NeedsRestart {
-				x.hasReboot = true
-			}
-		}
+	if cached, ok := commitCache[c]; ok {
+		return &cached, nil
 	}
-	return float64(alive)
-}
 
-func (x *xinStatus) uptodateCount() float64 {
-	utd := 0
-	for _, s := range x.config.Statuses {
-		if s.upToDate {
-			utd = utd + 1
-		}
+	repo, err := x.openRepo()
+	if err != nil {
+		return nil, err
 	}
-	return float64(utd)
+	err = commit.getInfo(repo)
+	if err != nil {
+		return nil, err
+	}
+	commitCache[c] = *commit
+
+	return commit, nil
 }
 
-func (x *xinStatus) getCommit(c string) (*commit, error) {
-	commit := &commit{
-		hash: c,
+// maxChangelog limits how far back we walk when working out what a host is
+// missing.
+const maxChangelog = 500
+
+// updateChangelog works out which upstream commits s doesn't have yet and
+// which state that puts the host in.
+func (x *xinStatus) updateChangelog(s *Status) {
+	s.behind = nil
+
+	rev := s.report.ConfigurationRevision
+	switch {
+	case rev == x.repoCommit.hash:
+		s.state = stateCurrent
+		s.behindNote = "up to date"
+		return
+	case rev == "DIRTY":
+		s.state = stateDirty
+		s.behindNote = "running a dirty tree"
+		return
+	case x.config.Repo == "":
+		x.placeInFeed(s, rev)
+		return
 	}
-	if c == "DIRTY" {
-		return commit, nil
+
+	s.state = stateUnknownRev
+
+	repo, err := x.openRepo()
+	if err != nil {
+		s.behindNote = err.Error()
+		return
 	}
 
-	if commit, ok := commitCache[c]; ok {
-		return &commit, nil
-	} else {
-		commit := NewCommit(c)
-		err := commit.getInfo(x.config.Repo)
-		if err != nil {
-			return nil, err
+	if _, err := repo.readCommit(rev); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			s.behindNote = fmt.Sprintf("%.8s is not in the repository (force-pushed?)", rev)
+		} else {
+			s.behindNote = err.Error()
 		}
-		commitCache[c] = *commit
+		return
 	}
 
-	return commit, nil
+	behind, truncated, err := repo.revList(x.repoCommit.hash, rev, maxChangelog)
+	if err != nil {
+		s.behindNote = err.Error()
+		return
+	}
+	s.behind = behind
+
+	ahead, _, err := repo.revList(rev, x.repoCommit.hash, 1)
+	if err != nil {
+		s.behindNote = err.Error()
+		return
+	}
+
+	switch {
+	case len(behind) > 0 && len(ahead) > 0:
+		s.state = stateDiverged
+	case len(ahead) > 0:
+		s.state = stateAhead
+	default:
+		s.state = stateBehind
+	}
+
+	switch {
+	case s.state == stateAhead:
+		s.behindNote = fmt.Sprintf("%.8s is ahead of %.8s", rev, x.repoCommit.hash)
+	case truncated:
+		s.behindNote = fmt.Sprintf("more than %d commits behind", len(behind))
+	case len(behind) == 1:
+		s.behindNote = "1 commit behind"
+	default:
+		s.behindNote = fmt.Sprintf("%d commits behind", len(behind))
+	}
+}
+
+func (s *Status) pendingChanges() string {
+	var lines []string
+	for _, c := range s.behind {
+		lines = append(lines, fmt.Sprintf("%.8s %s", c.hash, c.subject))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (x *xinStatus) openRepo() (*gitRepo, error) {
+	if x.repo != nil {
+		return x.repo, nil
+	}
+	repo, err := openRepo(x.config.Repo)
+	if err != nil {
+		return nil, err
+	}
+	x.repo = repo
+	return repo, nil
 }
 
 func (x *xinStatus) updateRepoInfo() error {
 	switch {
 	case (x.config.Repo != "" && x.config.FlakeRSS == ""):
-		revCmd := exec.Command("git", "rev-parse", "HEAD")
-		revCmd.Dir = x.config.Repo
-		currentRev, err := revCmd.Output()
+		repo, err := x.openRepo()
+		if err != nil {
+			return err
+		}
+		ref := x.config.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		currentRev, err := repo.resolveCommit(ref)
 		if err != nil {
 			return err
 		}
 
-		commit, err := x.getCommit(trim(currentRev))
+		commit, err := x.getCommit(currentRev)
 		if err != nil {
 			return err
 		}
 		x.repoCommit = *commit
+		x.trackedRef = fmt.Sprintf("tracking %s at %.8s", ref, currentRev)
 	default:
 		resp := &Feed{}
 		res, err := http.Get(x.config.FlakeRSS)
@@ -277,6 +479,8 @@ func (x *xinStatus) updateRepoInfo() error {
 			return err
 		}
 		x.repoCommit = *cmit
+		x.feedHashes = resp.Hashes()
+		x.trackedRef = fmt.Sprintf("tracking %s at %.8s", x.config.FlakeRSS, cmit.hash)
 	}
 
 	return nil
@@ -287,13 +491,17 @@ func (x *xinStatus) updateHostInfo() error {
 	if err != nil {
 		return err
 	}
-	upToDateCount := len(x.config.Statuses)
 	for _, s := range x.config.Statuses {
 		s := s
 		var err error
 		sshReset := func(reason string, err error) {
-			upToDateCount--
 			s.clientEstablished = false
+			s.state = stateOffline
+			s.health = nil
+			fyne.Do(func() {
+				s.badge.set(s.displayState())
+				s.healthBadge.setHealth(s.health)
+			})
 
 			if len(s.buttonBox.Objects) > 1 {
 				s.buttonBox.RemoveAll()
@@ -305,45 +513,21 @@ func (x *xinStatus) updateHostInfo() error {
 
 			log.Println(reason, err)
 		}
-		ds := fmt.Sprintf("%s:%d", s.Host, s.Port)
+		ds := s.addr()
 		if !s.clientEstablished {
 			log.Printf("establishing connection to %q", s.Host)
 			wakeButton := widget.NewButton("Wake", func() {
-				go func() {
-					log.Printf("sending wake to %s", s.Host)
-					mac, err := net.ParseMAC(s.MAC)
-					if err != nil {
-						log.Println(err)
-					}
-					sendMagicPacket(mac)
-				}()
+				x.wake(s)
 			})
+			if s.mac == nil {
+				wakeButton.Disable()
+			}
 			restartButton := widget.NewButton("Reboot", func() {
-				go func() {
-					cnf := dialog.NewConfirm("Confirmation", fmt.Sprintf("Are you sure you want to reboot %q?", s.Host), func(doit bool) {
-						if doit {
-							err := s.RunCmd("xin reboot", x)
-							if err != nil {
-								log.Println(err)
-							}
-							s.SshClose()
-						}
-					}, x.window)
-					cnf.SetDismissText("Cancel")
-					cnf.SetConfirmText("Ok")
-					cnf.Show()
-
-				}()
+				x.confirmReboot(s)
 			})
 
 			updateButton := widget.NewButton("Update", func() {
-				go func() {
-					err := s.RunCmd("xin update", x)
-					if err != nil {
-						log.Println(err)
-					}
-					s.SshClose()
-				}()
+				go x.runJob(s, actionUpdate, false, x.update)
 			})
 
 			if len(s.buttonBox.Objects) == 0 {
@@ -408,41 +592,74 @@ func (x *xinStatus) updateHostInfo() error {
 			continue
 		}
 
-		err = json.Unmarshal(output, s)
+		warningsChanged, err := s.applyReport(output)
 		if err != nil {
 			sshReset("can't unmarshal output", err)
 			continue
 		}
+		if warningsChanged {
+			for _, w := range s.warnings {
+				log.Printf("%s: xin status: %s", s.PrettyName(), w)
+			}
+		}
+		s.lastSeen = time.Now()
+		s.lost = false
 
-		if s.ConfigurationRevision != x.repoCommit.hash {
-			s.card.Subtitle = fmt.Sprintf("%.8s", s.ConfigurationRevision)
-			upToDateCount = upToDateCount - 1
+		x.updateChangelog(s)
+		s.trackStaleness()
+		x.checkHealth(s)
+		x.collectMetrics(s)
+
+		if s.state != stateCurrent {
+			s.setSubtitle(fmt.Sprintf("%.8s, %s", s.report.ConfigurationRevision, s.behindNote), s.state.role())
 		} else {
-			s.card.Subtitle = ""
+			s.setSubtitle("", roleCurrent)
 		}
 
-		fyne.Do(s.card.Refresh)
+		fyne.Do(func() {
+			s.badge.set(s.displayState())
+			s.healthBadge.setHealth(s.health)
+			s.card.Refresh()
+		})
+		if s.table != nil {
+			fyne.Do(s.table.Refresh)
+		}
+		if s.diffView != nil {
+			diff := s.systemDiff()
+			fyne.Do(func() { s.diffView.set(diff) })
+		}
+		x.updatePackages(s)
 
-		commit, err := x.getCommit(s.ConfigurationRevision)
+		cmit, err := x.getCommit(s.report.ConfigurationRevision)
 		if err != nil {
 			x.Log(err.Error())
+			s.commit = commit{
+				hash:    s.report.ConfigurationRevision,
+				subject: s.behindNote,
+			}
 			continue
 		}
-		s.commit = *commit
-
-		s.upToDate = false
-		if s.commit.hash == x.repoCommit.hash {
-			s.upToDate = true
-		}
+		s.commit = *cmit
 	}
 
+	x.summary = summarize(x.config.Statuses)
 	fyne.Do(func() {
-		x.upgradeProgress.SetValue(float64(upToDateCount))
+		x.updateProgress()
+		x.window.SetTitle("xintray: " + x.summaryText())
 	})
 
 	return nil
 }
 
+func (x *xinStatus) summaryText() string {
+	return x.summary.describe(x.repoCommit.hash)
+}
+
+func (x *xinStatus) updateProgress() {
+	x.upgradeProgress.Max = float64(x.summary.Reachable)
+	x.upgradeProgress.SetValue(float64(x.summary.Current))
+}
+
 func (x *xinStatus) Log(s string) {
 	log.Println(s)
 	/*
@@ -462,14 +679,40 @@ func (c *Config) Load(file string) error {
 		return err
 	}
 
-	return json.Unmarshal(data, &c)
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return err
+	}
+
+	c.validate()
+
+	return nil
+}
+
+// validate checks per-host settings that we'd otherwise only trip over
+// when they're used.
+func (c *Config) validate() {
+	for _, s := range c.Statuses {
+		if s.MAC == "" {
+			continue
+		}
+		mac, err := net.ParseMAC(s.MAC)
+		switch {
+		case err != nil:
+			log.Printf("%s: invalid MAC %q, wake disabled: %s", s.PrettyName(), s.MAC, err)
+		case len(mac) != 6:
+			log.Printf("%s: MAC %q is not an Ethernet address, wake disabled", s.PrettyName(), s.MAC)
+		default:
+			s.mac = mac
+		}
+	}
 }
 
 func (s *Status) ToTable() *widget.Table {
 	t := widget.NewTable(
 		// Length
 		func() (int, int) {
-			return 7, 2
+			return 8 + len(s.extraRows()), 2
 		},
 		// CreateCell
 		func() fyne.CanvasObject {
@@ -497,37 +740,41 @@ func (s *Status) ToTable() *widget.Table {
 				case 5:
 					content.SetText("Restart?")
 				case 6:
-					content.SetText("System Diff")
+					content.SetText("Commits Behind")
+				case 7:
+					content.SetText("Pending Changes")
 				}
 			}
 			if i.Col == 1 {
 				switch i.Row {
 				case 0:
-					content.SetText(s.NixosVersion)
+					content.SetText(s.report.NixosVersion)
 				case 1:
-					content.SetText(s.NixpkgsRevision)
+					content.SetText(s.report.NixpkgsRevision)
 				case 2:
-					content.SetText(s.Uname)
+					content.SetText(s.report.Uname)
 				case 3:
-					content.SetText(s.Uptime)
+					content.SetText(s.report.Uptime)
 				case 4:
-					content.SetText(s.ConfigurationRevision)
+					content.SetText(s.report.ConfigurationRevision)
 				case 5:
 					str := "No"
-					if s.NeedsRestart {
+					if s.report.NeedsRestart {
 						str = "Yes"
 					}
 					content.SetText(str)
 				case 6:
-					text, err := base64.StdEncoding.DecodeString(s.SystemDiff)
-					if err != nil {
-						fmt.Println("decode error:", err)
-						return
-					}
-					content.SetText(string(text))
+					content.SetText(s.behindNote)
+				case 7:
+					content.SetText(s.pendingChanges())
 				}
 
 			}
+			if i.Row >= 8 {
+				if rows := s.extraRows(); i.Row-8 < len(rows) {
+					content.SetText(rows[i.Row-8][i.Col])
+				}
+			}
 		},
 		// OnSelected
 		// func (i widget.TableCellID) {}
@@ -539,7 +786,9 @@ func (s *Status) ToTable() *widget.Table {
 
 	t.SetColumnWidth(0, 300.0)
 	t.SetColumnWidth(1, 600.0)
-	t.SetRowHeight(6, 600.0)
+	t.SetRowHeight(7, 300.0)
+
+	s.table = t
 
 	return t
 }
@@ -552,16 +801,16 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 	for _, s := range stat.config.Statuses {
 		// TODO: maybe not needed once loopvar stuff is solid?
 		s := s
-		commitBStr := binding.BindString(&s.commit.message)
+		commitBStr := binding.BindString(&s.commit.subject)
 		bsl := widget.NewLabelWithData(commitBStr)
 
-		verBStr := binding.BindString(&s.NixosVersion)
+		verBStr := binding.BindString(&s.report.NixosVersion)
 		bvl := widget.NewLabelWithData(verBStr)
 
-		uptimeBStr := binding.BindString(&s.Uptime)
+		uptimeBStr := binding.BindString(&s.report.Uptime)
 		uvl := widget.NewLabelWithData(uptimeBStr)
 
-		restartBBool := binding.BindBool(&s.NeedsRestart)
+		restartBBool := binding.BindBool(&s.report.NeedsRestart)
 		bbl := widget.NewCheckWithData("Reboot", restartBBool)
 		bbl.Disable()
 
@@ -570,15 +819,42 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 		stat.boundStrings = append(stat.boundStrings, uptimeBStr)
 		stat.boundBools = append(stat.boundBools, restartBBool)
 
+		scheduleBStr := binding.BindString(&s.scheduleNote)
+		stat.boundStrings = append(stat.boundStrings, scheduleBStr)
+		scheduleButton := widget.NewButton("Schedule…", func() {
+			stat.showScheduleDialog(fmt.Sprintf("Schedule %s", s.PrettyName()), []*Status{s})
+		})
+		cleanButton := widget.NewButton("Clean…", func() {
+			stat.showCleanDialog(fmt.Sprintf("Clean %s", s.PrettyName()), []*Status{s})
+		})
+		generationsButton := widget.NewButton("Generations…", func() {
+			stat.showGenerations(s)
+		})
+
 		buttonHBox := container.NewHBox()
+		s.badge = newStateBadge()
+		s.healthBadge = newStateBadge()
+		s.healthBadge.setHealth(nil)
+		s.metrics = &hostMetrics{}
+		s.metricsView = newMetricsView()
+		s.wakeProgress = widget.NewProgressBarInfinite()
+		s.wakeProgress.Hide()
+
+		s.subtitle = canvas.NewText("", activePalette.color(roleOffline))
+		s.subtitle.Hide()
 
 		card := widget.NewCard(s.PrettyName(), "",
 			container.NewVBox(
+				s.subtitle,
+				container.NewHBox(s.badge.box, s.healthBadge.box),
 				container.NewHBox(bvl),
 				container.NewHBox(uvl),
 				container.NewHBox(bbl),
 				container.NewHBox(bsl),
-				buttonHBox,
+				s.metricsView.box,
+				s.wakeProgress,
+				widget.NewLabelWithData(scheduleBStr),
+				container.NewHBox(buttonHBox, scheduleButton, cleanButton, generationsButton),
 			),
 		)
 
@@ -590,19 +866,25 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 
 	stat.upgradeProgress = widget.NewProgressBar()
 	stat.upgradeProgress.Min = 0
-	stat.upgradeProgress.Max = stat.aliveCount()
+	stat.upgradeProgress.Max = 0
 	stat.upgradeProgress.TextFormatter = func() string {
-		return fmt.Sprintf("%.0f of %.0f hosts up-to-date",
-			stat.upgradeProgress.Value, stat.upgradeProgress.Max)
+		return fmt.Sprintf("%d of %d hosts up-to-date",
+			stat.summary.Current, stat.summary.Reachable)
 	}
 
-	bsCommitMsg := binding.BindString(&stat.repoCommit.message)
+	bsCommitMsg := binding.BindString(&stat.repoCommit.subject)
 	bsCommitHash := binding.BindString(&stat.repoCommit.hash)
+	bsTrackedRef := binding.BindString(&stat.trackedRef)
 
 	stat.boundStrings = append(stat.boundStrings, bsCommitMsg)
 	stat.boundStrings = append(stat.boundStrings, bsCommitHash)
+	stat.boundStrings = append(stat.boundStrings, bsTrackedRef)
 
 	ciStart := widget.NewButton("CI Start", func() {
+		if stat.ci == nil {
+			stat.showError(errNoCI)
+			return
+		}
 		go func() {
 			err := stat.ci.RunCmd("xin ci start", stat)
 			if err != nil {
@@ -611,6 +893,10 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 		}()
 	})
 	ciUpdate := widget.NewButton("CI Update", func() {
+		if stat.ci == nil {
+			stat.showError(errNoCI)
+			return
+		}
 		go func() {
 			err := stat.ci.RunCmd("xin ci update", stat)
 			if err != nil {
@@ -618,22 +904,34 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 			}
 		}()
 	})
+	rebootAll := widget.NewButton("Reboot All Needing", func() {
+		stat.confirmRebootAll()
+	})
+	scheduleGroup := widget.NewButton("Schedule Group…", func() {
+		stat.showGroupScheduleDialog()
+	})
+	cleanAll := widget.NewButton("Clean All…", stat.cleanAll)
 	updateAll := widget.NewButton("Update All", func() {
 		for _, s := range stat.config.Statuses {
 			host := s
 			log.Printf("updating %s", host.Host)
-			go func() {
-				err := host.RunCmd("xin update", stat)
-				if err != nil {
-					log.Println(err)
-				}
-			}()
+			go stat.runJob(host, actionUpdate, false, func(s *Status) error {
+				s.updating = true
+				defer func() { s.updating = false }()
+				return s.RunCmd("xin update", stat)
+			})
 		}
 	})
 
+	pauseAuto := widget.NewCheck("Pause automatic updates and schedules", nil)
+	pauseAuto.SetChecked(stat.paused())
+	pauseAuto.OnChanged = stat.setAutoPaused
+
 	statusCard := widget.NewCard("Xin Status", "", container.NewVBox(
+		widget.NewLabelWithData(bsTrackedRef),
 		widget.NewLabelWithData(bsCommitMsg),
-		container.NewHBox(ciStart, ciUpdate, updateAll),
+		container.NewHBox(ciStart, ciUpdate, updateAll, rebootAll, scheduleGroup, cleanAll),
+		pauseAuto,
 		stat.upgradeProgress,
 	))
 	stat.cards = append(cards, statusCard)
@@ -646,6 +944,14 @@ func buildCards(stat *xinStatus) fyne.CanvasObject {
 
 func main() {
 	log.SetPrefix("xintray: ")
+
+	wolListen := flag.String("wol-listen", "", "log magic packets received on `addr` (e.g. :9) instead of starting the UI")
+	flag.Parse()
+
+	if *wolListen != "" {
+		log.Fatal(runWakeListener(*wolListen))
+	}
+
 	status := &xinStatus{}
 	dataPath := path.Clean(path.Join(os.Getenv("HOME"), ".xin.json"))
 	err := status.config.Load(dataPath)
@@ -653,8 +959,24 @@ func main() {
 		log.Fatal(err)
 	}
 
+	activePalette = newPalette(status.config.Palette)
+
+	status.jobs = newJobLog()
+	status.packages = newPkgMatrix(status.config.Statuses)
+	status.autoPaused = loadAutoPaused(status.config.AutoPaused)
+
+	status.pins = newPinList()
+	if err := status.pins.load(); err != nil {
+		log.Println(err)
+	}
+
+	status.schedules = newScheduler()
+	if err := status.schedules.load(); err != nil {
+		log.Println(err)
+	}
+
 	a := app.New()
-	a.Settings().SetTheme(&xinTheme{})
+	a.Settings().SetTheme(&xinTheme{palette: activePalette})
 	w := a.NewWindow("xintray")
 	if w == nil {
 		log.Fatalln("unable to create window")
@@ -694,19 +1016,17 @@ func main() {
 			if err != nil {
 				status.log.SetText(err.Error())
 			}
-			for _, s := range status.boundStrings {
-				s.Reload()
-			}
-			for _, s := range status.boundBools {
-				s.Reload()
-			}
+			status.checkReboots()
+			status.runSchedules()
+			status.runAutoUpdates()
+			status.reloadBindings()
 			time.Sleep(3 * time.Second)
-			status.upgradeProgress.Max = status.aliveCount()
 		}
 	}()
 
 	for _, s := range status.config.Statuses {
-		tabs.Append(container.NewTabItem(s.PrettyName(), s.ToTable()))
+		s.diffView = newDiffView()
+		tabs.Append(container.NewTabItem(s.PrettyName(), container.NewVSplit(s.ToTable(), s.diffView.box)))
 	}
 
 	tabs.SetTabLocation(container.TabLocationLeading)
@@ -716,30 +1036,25 @@ func main() {
 
 	if desk, ok := a.(desktop.App); ok {
 		iconImg := buildImage(status)
-		m := fyne.NewMenu("xintray",
-			fyne.NewMenuItem("Show", func() {
-				w.Show()
-			}),
-			fyne.NewMenuItem("Run CI", func() {
-				err := status.ci.RunCmd("xin ci start", status)
-				if err != nil {
-					log.Println(err)
-				}
-			}),
-			fyne.NewMenuItem("Update", func() {
-				err := status.ci.RunCmd("xin ci update", status)
-				if err != nil {
-					log.Println(err)
-				}
-			}))
-		desk.SetSystemTrayMenu(m)
+		desk.SetSystemTrayMenu(status.buildTrayMenu())
 		desk.SetSystemTrayIcon(iconImg)
 		a.SetIcon(iconImg)
 		go func() {
+			lastIcon, lastMenu := "", menuKey(status)
 			for {
-				img := buildImage(status)
-				desk.SetSystemTrayIcon(img)
-				a.SetIcon(img)
+				// Only re-render when something visible has changed.
+				if key := iconKey(status); key != lastIcon {
+					lastIcon = key
+					img := buildImage(status)
+					desk.SetSystemTrayIcon(img)
+					a.SetIcon(img)
+				}
+				if key := menuKey(status); key != lastMenu {
+					lastMenu = key
+					fyne.Do(func() {
+						desk.SetSystemTrayMenu(status.buildTrayMenu())
+					})
+				}
 				time.Sleep(3 * time.Second)
 			}
 		}()
@@ -750,6 +1065,8 @@ func main() {
 	w.SetContent(container.NewAppTabs(
 		container.NewTabItem("Hosts", tabs),
 		container.NewTabItem("Config", container.NewStack(widget.NewCard("Config", "", nil))),
+		container.NewTabItem("Packages", status.packages.widget(status)),
+		container.NewTabItem("Jobs", status.jobs.widget()),
 		container.NewTabItem("Logs", container.NewStack(status.log)),
 	))
 	w.SetCloseIntercept(func() {
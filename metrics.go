@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	metricsCmd = "echo '# load'; cat /proc/loadavg; " +
+		"echo '# cpus'; nproc; " +
+		"echo '# mem'; cat /proc/meminfo; " +
+		"echo '# df'; df -P -B1 / /nix/store"
+	storeSizeCmd = "du -sb /nix/store"
+
+	metricsInterval   = 30 * time.Second
+	storeSizeInterval = time.Hour
+	metricsHistory    = 120
+)
+
+// diskPaths are the paths passed to df in metricsCmd, in order.
+var diskPaths = []string{"/", "/nix"}
+
+type diskUsage struct {
+	size      uint64
+	used      uint64
+	available uint64
+}
+
+// percent is how full the disk is the way df works it out, leaving out
+// blocks reserved for root.
+func (d diskUsage) percent() float64 {
+	if d.used+d.available == 0 {
+		return 0
+	}
+	return float64(d.used) / float64(d.used+d.available) * 100
+}
+
+// metricSample is one round of resource metrics from a host.
+type metricSample struct {
+	at           time.Time
+	load         [3]float64
+	cpus         int
+	memTotal     uint64
+	memAvailable uint64
+	disks        map[string]diskUsage
+}
+
+func (m *metricSample) memPercent() float64 {
+	if m.memTotal == 0 {
+		return 0
+	}
+	return float64(m.memTotal-m.memAvailable) / float64(m.memTotal) * 100
+}
+
+func parseMetrics(out string) (*metricSample, error) {
+	m := &metricSample{
+		at:    time.Now(),
+		disks: make(map[string]diskUsage),
+	}
+
+	section := ""
+	disk := 0
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "# ") {
+			section = line[2:]
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch section {
+		case "load":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid loadavg %q", line)
+			}
+			for i := range m.load {
+				v, err := strconv.ParseFloat(fields[i], 64)
+				if err != nil {
+					return nil, err
+				}
+				m.load[i] = v
+			}
+		case "cpus":
+			n, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			m.cpus = n
+		case "mem":
+			if len(fields) < 2 {
+				continue
+			}
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "MemTotal:":
+				m.memTotal = kb * 1024
+			case "MemAvailable:":
+				m.memAvailable = kb * 1024
+			}
+		case "df":
+			if fields[0] == "Filesystem" || len(fields) < 4 || disk >= len(diskPaths) {
+				continue
+			}
+			var n [3]uint64
+			for i := range n {
+				v, err := strconv.ParseUint(fields[i+1], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				n[i] = v
+			}
+			m.disks[diskPaths[disk]] = diskUsage{size: n[0], used: n[1], available: n[2]}
+			disk++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if m.memTotal == 0 {
+		return nil, fmt.Errorf("no memory information in metrics output")
+	}
+	return m, nil
+}
+
+// thresholds are the levels at which metrics get flagged on a card.
+type thresholds struct {
+	Disk   float64 `json:"disk"`
+	Memory float64 `json:"memory"`
+	Load   float64 `json:"load"` // per CPU
+}
+
+func (t thresholds) withDefaults() thresholds {
+	if t.Disk == 0 {
+		t.Disk = 90
+	}
+	if t.Memory == 0 {
+		t.Memory = 90
+	}
+	if t.Load == 0 {
+		t.Load = 2
+	}
+	return t
+}
+
+// hostMetrics keeps recent samples for a host.
+type hostMetrics struct {
+	mu            sync.Mutex
+	samples       []*metricSample
+	storeSize     uint64
+	storeChecked  time.Time
+	storeChecking bool
+}
+
+func (h *hostMetrics) add(m *metricSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples = append(h.samples, m)
+	if len(h.samples) > metricsHistory {
+		h.samples = h.samples[len(h.samples)-metricsHistory:]
+	}
+}
+
+func (h *hostMetrics) latest() *metricSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) == 0 {
+		return nil
+	}
+	return h.samples[len(h.samples)-1]
+}
+
+// series returns the history of one metric, oldest first.
+func (h *hostMetrics) series(f func(*metricSample) float64) []float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	vals := make([]float64, len(h.samples))
+	for i, m := range h.samples {
+		vals[i] = f(m)
+	}
+	return vals
+}
+
+func (h *hostMetrics) summary() string {
+	m := h.latest()
+	if m == nil {
+		return "no metrics yet"
+	}
+	parts := []string{
+		fmt.Sprintf("load %.2f", m.load[0]),
+		fmt.Sprintf("mem %.0f%%", m.memPercent()),
+	}
+	for _, p := range diskPaths {
+		if d, ok := m.disks[p]; ok {
+			parts = append(parts, fmt.Sprintf("%s %.0f%%", p, d.percent()))
+		}
+	}
+	h.mu.Lock()
+	if h.storeSize > 0 {
+		parts = append(parts, fmt.Sprintf("store %s", formatBytes(h.storeSize)))
+	}
+	h.mu.Unlock()
+	return strings.Join(parts, "  ")
+}
+
+// flags describes each metric over its threshold.
+func (h *hostMetrics) flags(t thresholds) []string {
+	m := h.latest()
+	if m == nil {
+		return nil
+	}
+	var flags []string
+	for _, p := range diskPaths {
+		if d, ok := m.disks[p]; ok && d.percent() > t.Disk {
+			flags = append(flags, fmt.Sprintf("%s over %.0f%%", p, t.Disk))
+		}
+	}
+	if m.memPercent() > t.Memory {
+		flags = append(flags, fmt.Sprintf("memory over %.0f%%", t.Memory))
+	}
+	if m.cpus > 0 && m.load[0]/float64(m.cpus) > t.Load {
+		flags = append(flags, fmt.Sprintf("load over %.1f per CPU", t.Load))
+	}
+	return flags
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// collectMetrics samples resource usage on s over its existing connection.
+// The store size is slow to work out, so it's done in the background and
+// much less often.
+func (x *xinStatus) collectMetrics(s *Status) {
+	h := s.metrics
+	if m := h.latest(); m != nil && time.Since(m.at) < metricsInterval {
+		return
+	}
+
+	out, err := s.Exec(metricsCmd, nil)
+	if err != nil {
+		log.Printf("%s: collecting metrics: %s", s.PrettyName(), err)
+		return
+	}
+	m, err := parseMetrics(string(out))
+	if err != nil {
+		log.Printf("%s: parsing metrics: %s", s.PrettyName(), err)
+		return
+	}
+	h.add(m)
+
+	h.mu.Lock()
+	sizeDue := !h.storeChecking && time.Since(h.storeChecked) > storeSizeInterval
+	if sizeDue {
+		h.storeChecking = true
+	}
+	h.mu.Unlock()
+	if sizeDue {
+		go func() {
+			out, err := s.Exec(storeSizeCmd, nil)
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			h.storeChecking = false
+			h.storeChecked = time.Now()
+			if err != nil {
+				log.Printf("%s: measuring store: %s", s.PrettyName(), err)
+				return
+			}
+			fields := strings.Fields(string(out))
+			if len(fields) > 0 {
+				h.storeSize, _ = strconv.ParseUint(fields[0], 10, 64)
+			}
+		}()
+	}
+
+	fyne.Do(func() { s.metricsView.refresh(h, x.config.Thresholds.withDefaults()) })
+}
+
+// sparkline draws a series as a small line graph.
+type sparkline struct {
+	values []float64
+	max    float64
+	raster *canvas.Raster
+}
+
+func newSparkline(max float64) *sparkline {
+	sl := &sparkline{max: max}
+	sl.raster = canvas.NewRaster(sl.draw)
+	sl.raster.SetMinSize(fyne.NewSize(80, 20))
+	return sl
+}
+
+func (sl *sparkline) set(values []float64) {
+	sl.values = values
+	sl.raster.Refresh()
+}
+
+func (sl *sparkline) draw(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if len(sl.values) < 2 || w < 2 || h < 2 {
+		return img
+	}
+
+	max := sl.max
+	for _, v := range sl.values {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return img
+	}
+
+	c := activePalette.color(roleCurrent)
+	y := func(v float64) int {
+		return h - 1 - int(v/max*float64(h-1))
+	}
+	prevY := y(sl.values[0])
+	for px := 0; px < w; px++ {
+		i := px * (len(sl.values) - 1) / (w - 1)
+		cy := y(sl.values[i])
+		lo, hi := prevY, cy
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		for py := lo; py <= hi; py++ {
+			img.Set(px, py, c)
+		}
+		prevY = cy
+	}
+	return img
+}
+
+// metricsView is the metrics section of a host card.
+type metricsView struct {
+	summary *widget.Label
+	flags   *widget.Label
+	load    *sparkline
+	mem     *sparkline
+	nix     *sparkline
+	box     *fyne.Container
+}
+
+func newMetricsView() *metricsView {
+	v := &metricsView{
+		summary: widget.NewLabel("no metrics yet"),
+		flags:   widget.NewLabel(""),
+		load:    newSparkline(1),
+		mem:     newSparkline(100),
+		nix:     newSparkline(100),
+	}
+	v.flags.Importance = widget.WarningImportance
+	v.flags.Hide()
+	v.box = container.NewVBox(
+		v.summary,
+		container.NewHBox(
+			widget.NewLabel("load"), v.load.raster,
+			widget.NewLabel("mem"), v.mem.raster,
+			widget.NewLabel("/nix"), v.nix.raster,
+		),
+		v.flags,
+	)
+	return v
+}
+
+// refresh must be called on the UI thread.
+func (v *metricsView) refresh(h *hostMetrics, t thresholds) {
+	v.summary.SetText(h.summary())
+	v.load.set(h.series(func(m *metricSample) float64 { return m.load[0] }))
+	v.mem.set(h.series((*metricSample).memPercent))
+	v.nix.set(h.series(func(m *metricSample) float64 { return m.disks["/nix"].percent() }))
+
+	if flags := h.flags(t); len(flags) > 0 {
+		v.flags.SetText("⚠ " + strings.Join(flags, ", "))
+		v.flags.Show()
+	} else {
+		v.flags.Hide()
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// metricsOutput is what metricsCmd prints on a typical host.
+const metricsOutput = `# load
+0.52 0.58 0.59 2/1234 56789
+# cpus
+8
+# mem
+MemTotal:       32594660 kB
+MemFree:         1043212 kB
+MemAvailable:   24445996 kB
+Buffers:          412332 kB
+Cached:         20519180 kB
+SwapCached:            0 kB
+HugePages_Total:       0
+Hugepagesize:       2048 kB
+# df
+Filesystem        1-blocks         Used    Available Capacity Mounted on
+/dev/nvme0n1p2 502392610816 452153349734 24626974720      95% /
+/dev/nvme0n1p2 502392610816 452153349734 24626974720      95% /nix/store
+`
+
+func TestParseMetrics(t *testing.T) {
+	m, err := parseMetrics(metricsOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := [3]float64{0.52, 0.58, 0.59}; m.load != want {
+		t.Errorf("load = %v, want %v", m.load, want)
+	}
+	if m.cpus != 8 {
+		t.Errorf("cpus = %d, want 8", m.cpus)
+	}
+	if m.memTotal != 32594660*1024 || m.memAvailable != 24445996*1024 {
+		t.Errorf("mem = %d total, %d available", m.memTotal, m.memAvailable)
+	}
+	if got := m.memPercent(); got < 24.9 || got > 25.1 {
+		t.Errorf("memPercent() = %.2f, want 25", got)
+	}
+	want := map[string]diskUsage{
+		"/":    {size: 502392610816, used: 452153349734, available: 24626974720},
+		"/nix": {size: 502392610816, used: 452153349734, available: 24626974720},
+	}
+	if !reflect.DeepEqual(m.disks, want) {
+		t.Errorf("disks = %v, want %v", m.disks, want)
+	}
+}
+
+func TestParseMetricsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+	}{
+		{"empty", ""},
+		{"no memory", "# load\n0.1 0.2 0.3 1/1 1\n# cpus\n2\n"},
+		{"short loadavg", "# load\n0.1 0.2\n"},
+		{"bad loadavg", "# load\nhigh 0.2 0.3 1/1 1\n"},
+		{"bad cpus", "# cpus\nmany\n"},
+		{"bad df", "# mem\nMemTotal: 1024 kB\n# df\n/dev/sda1 big 1 1 1% /\n"},
+		{"bad df available", "# mem\nMemTotal: 1024 kB\n# df\n/dev/sda1 10 1 lots 1% /\n"},
+	}
+	for _, tt := range tests {
+		if _, err := parseMetrics(tt.out); err == nil {
+			t.Errorf("%s: parseMetrics() succeeded", tt.name)
+		}
+	}
+}
+
+func TestMetricsFlags(t *testing.T) {
+	m, err := parseMetrics(metricsOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &hostMetrics{}
+	h.add(m)
+
+	want := []string{"/ over 90%", "/nix over 90%"}
+	if got := h.flags(thresholds{}.withDefaults()); !reflect.DeepEqual(got, want) {
+		t.Errorf("flags() = %q, want %q", got, want)
+	}
+
+	want = []string{"memory over 20%", "load over 0.1 per CPU"}
+	if got := h.flags(thresholds{Disk: 99, Memory: 20, Load: 0.05}); !reflect.DeepEqual(got, want) {
+		t.Errorf("flags() = %q, want %q", got, want)
+	}
+
+	if got, want := h.summary(), "load 0.52  mem 25%  / 95%  /nix 95%"; got != want {
+		t.Errorf("summary() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const closureQueryCmd = "nix-store -qR /run/current-system"
+
+// storeOutputs are the output names nix appends to the store paths of
+// outputs other than "out", e.g. openssl-3.0.12-bin.
+var storeOutputs = map[string]bool{
+	"bin": true, "dev": true, "lib": true, "man": true,
+	"doc": true, "devdoc": true, "info": true, "debug": true, "static": true,
+	"modules": true, "python": true, "terminfo": true, "getent": true,
+}
+
+// trimOutput drops an output suffix from a version so the outputs of a
+// package count as one version.
+func trimOutput(version string) string {
+	if i := strings.LastIndex(version, "-"); i > 0 && storeOutputs[version[i+1:]] {
+		return version[:i]
+	}
+	return version
+}
+
+// splitStorePath splits a store path into a package name and version the
+// way nix does: the version starts at the first dash followed by a digit.
+// Output suffixes such as "-bin" are dropped from the version.
+func splitStorePath(p string) (string, string, bool) {
+	base := path.Base(strings.TrimSpace(p))
+	// Drop the hash.
+	_, nv, ok := strings.Cut(base, "-")
+	if !ok {
+		return "", "", false
+	}
+	for i := 0; i < len(nv)-1; i++ {
+		if nv[i] == '-' && unicode.IsDigit(rune(nv[i+1])) {
+			return nv[:i], trimOutput(nv[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// parseClosure turns "nix-store -qR" output into package versions.
+func parseClosure(out string) map[string]string {
+	seen := make(map[string][]string)
+	for _, line := range strings.Split(out, "\n") {
+		name, version, ok := splitStorePath(line)
+		if !ok {
+			continue
+		}
+		seen[name] = append(seen[name], version)
+	}
+
+	pkgs := make(map[string]string, len(seen))
+	for name, versions := range seen {
+		sort.Strings(versions)
+		pkgs[name] = strings.Join(dedup(versions), ", ")
+	}
+	return pkgs
+}
+
+func dedup(sorted []string) []string {
+	var out []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// diffPackages returns the versions a system diff says are now installed.
+func diffPackages(d *systemDiff) map[string]string {
+	pkgs := make(map[string]string)
+	for _, c := range d.changes {
+		if c.kind == changeRemoved || c.new == "" {
+			continue
+		}
+		// nvd lists each output, e.g. "3.0.13, 3.0.13-bin".
+		var versions []string
+		for _, v := range strings.Split(c.new, ",") {
+			versions = append(versions, trimOutput(strings.TrimSpace(v)))
+		}
+		sort.Strings(versions)
+		pkgs[c.name] = strings.Join(dedup(versions), ", ")
+	}
+	return pkgs
+}
+
+// pkgMatrix holds the known package versions for every host.
+type pkgMatrix struct {
+	mu    sync.Mutex
+	hosts []string
+	pkgs  map[string]map[string]string // host -> package -> version
+
+	names        []string
+	search       *widget.Entry
+	onlyDiverged *widget.Check
+	table        *widget.Table
+}
+
+func newPkgMatrix(hosts []*Status) *pkgMatrix {
+	m := &pkgMatrix{
+		pkgs: make(map[string]map[string]string),
+	}
+	for _, s := range hosts {
+		m.hosts = append(m.hosts, s.PrettyName())
+	}
+	return m
+}
+
+// set records the packages of host and reports whether they changed.
+func (m *pkgMatrix) set(host string, pkgs map[string]string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.pkgs[host]; ok && reflect.DeepEqual(old, pkgs) {
+		return false
+	}
+	m.pkgs[host] = pkgs
+	return true
+}
+
+func (m *pkgMatrix) version(host, name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pkgs[host][name]
+}
+
+// diverged reports whether hosts that have name disagree on its version.
+func (m *pkgMatrix) diverged(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first := ""
+	for _, h := range m.hosts {
+		v := m.pkgs[h][name]
+		if v == "" {
+			continue
+		}
+		if first == "" {
+			first = v
+		} else if v != first {
+			return true
+		}
+	}
+	return false
+}
+
+// filter recomputes the visible package names. It must be called on the
+// UI thread.
+func (m *pkgMatrix) filter() {
+	if m.table == nil {
+		return
+	}
+
+	m.mu.Lock()
+	all := make(map[string]bool)
+	for _, pkgs := range m.pkgs {
+		for name := range pkgs {
+			all[name] = true
+		}
+	}
+	m.mu.Unlock()
+
+	q := strings.ToLower(m.search.Text)
+	m.names = m.names[:0]
+	for name := range all {
+		if q != "" && !strings.Contains(strings.ToLower(name), q) {
+			continue
+		}
+		if m.onlyDiverged.Checked && !m.diverged(name) {
+			continue
+		}
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+
+	m.table.Refresh()
+}
+
+func (m *pkgMatrix) widget(x *xinStatus) fyne.CanvasObject {
+	m.search = widget.NewEntry()
+	m.search.SetPlaceHolder("Search packages")
+	m.search.OnChanged = func(string) { m.filter() }
+
+	m.onlyDiverged = widget.NewCheck("Only divergent", func(bool) { m.filter() })
+
+	m.table = widget.NewTable(
+		func() (int, int) {
+			return len(m.names) + 1, len(m.hosts) + 1
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(i widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.Importance = widget.MediumImportance
+			l.TextStyle = fyne.TextStyle{}
+			switch {
+			case i.Row == 0 && i.Col == 0:
+				l.TextStyle.Bold = true
+				l.SetText("Package")
+			case i.Row == 0:
+				l.TextStyle.Bold = true
+				l.SetText(m.hosts[i.Col-1])
+			case i.Col == 0:
+				name := m.names[i.Row-1]
+				if m.diverged(name) {
+					l.Importance = widget.WarningImportance
+				}
+				l.SetText(name)
+			default:
+				name := m.names[i.Row-1]
+				if m.diverged(name) {
+					l.Importance = widget.WarningImportance
+				}
+				l.SetText(m.version(m.hosts[i.Col-1], name))
+			}
+		},
+	)
+	m.table.SetColumnWidth(0, 250)
+	for i := range m.hosts {
+		m.table.SetColumnWidth(i+1, 150)
+	}
+
+	export := widget.NewButton("Export CSV…", func() {
+		dialog.ShowFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				x.showError(err)
+				return
+			}
+			if w == nil {
+				return
+			}
+			defer w.Close()
+			if err := m.writeCSV(w); err != nil {
+				x.showError(err)
+			}
+		}, x.window)
+	})
+
+	top := container.NewBorder(nil, nil, nil, container.NewHBox(m.onlyDiverged, export), m.search)
+	return container.NewBorder(top, nil, nil, nil, m.table)
+}
+
+// writeCSV writes the currently visible rows of the matrix.
+func (m *pkgMatrix) writeCSV(w fyne.URIWriteCloser) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"package"}, m.hosts...)); err != nil {
+		return err
+	}
+	for _, name := range m.names {
+		row := []string{name}
+		for _, h := range m.hosts {
+			row = append(row, m.version(h, name))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// updatePackages refreshes the package versions known for s. The system
+// diff only covers what changed, so when PackageQuery is set the full
+// closure is queried once per configuration revision.
+func (x *xinStatus) updatePackages(s *Status) {
+	pkgs := diffPackages(parseSystemDiff(s.systemDiff()))
+
+	if x.config.PackageQuery {
+		if s.packagesRev != s.report.ConfigurationRevision {
+			out, err := s.Exec(closureQueryCmd, nil)
+			if err != nil {
+				log.Println(fmt.Errorf("%s: querying closure: %w", s.PrettyName(), err))
+			} else {
+				s.closure = parseClosure(string(out))
+				s.packagesRev = s.report.ConfigurationRevision
+			}
+		}
+		for name, version := range s.closure {
+			pkgs[name] = version
+		}
+	}
+
+	if x.packages.set(s.PrettyName(), pkgs) {
+		fyne.Do(x.packages.filter)
+	}
+}
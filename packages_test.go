@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStorePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		name    string
+		version string
+		ok      bool
+	}{
+		{"/nix/store/0c7c3gbq8rbwr4ij0bbdwk5qmwm6nxkm-firefox-123.0", "firefox", "123.0", true},
+		{"/nix/store/1b9p07z77phvv2hf6gm9f28syp39f1ag-openssl-3.0.13", "openssl", "3.0.13", true},
+		{"/nix/store/5j3y2dhn5r5bl1ppwxmsxa0qsg6zgqkx-openssl-3.0.13-bin", "openssl", "3.0.13", true},
+		{"/nix/store/9v5d40jyvmwgnq1nj8f19ji2rcc5dksd-glibc-2.38-44", "glibc", "2.38-44", true},
+		{"/nix/store/a0l3wvw9rvmm4bqpwc1bnsrd2nb8dhjv-glibc-2.38-44-getent", "glibc", "2.38-44", true},
+		{"/nix/store/b6gvzjyb2pg0kjfwrjmg1vfhh54ad73z-linux-6.6.18-modules", "linux", "6.6.18", true},
+		{"/nix/store/c7s5jbnmbwnq9d7hr9k2fl9vjhn9s0xl-python3.11-requests-2.31.0", "python3.11-requests", "2.31.0", true},
+		{"/nix/store/d8z6m5b3h6p2q8ls9y3xnqg5fzlx0zn2-nixos-system-box-24.05.20240301.3a9c1c2", "nixos-system-box", "24.05.20240301.3a9c1c2", true},
+		{"  /nix/store/f9k0w1p2q3r4s5t6u7v8w9x0y1z2a3b4-bash-5.2p26  ", "bash", "5.2p26", true},
+		{"/nix/store/g0l1m2n3o4p5q6r7s8t9u0v1w2x3y4z5-etc", "", "", false},
+		{"/nix/store/h1m2n3o4p5q6r7s8t9u0v1w2x3y4z5a6-unit-script-nscd-start", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		name, version, ok := splitStorePath(tt.path)
+		if name != tt.name || version != tt.version || ok != tt.ok {
+			t.Errorf("splitStorePath(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.path, name, version, ok, tt.name, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestParseClosure(t *testing.T) {
+	// Trimmed "nix-store -qR /run/current-system" output.
+	out := `/nix/store/1b9p07z77phvv2hf6gm9f28syp39f1ag-openssl-3.0.13
+/nix/store/5j3y2dhn5r5bl1ppwxmsxa0qsg6zgqkx-openssl-3.0.13-bin
+/nix/store/6k4z3eio6s6cm2qqxynty1rth7a0hrly-openssl-1.1.1w
+/nix/store/9v5d40jyvmwgnq1nj8f19ji2rcc5dksd-glibc-2.38-44
+/nix/store/a0l3wvw9rvmm4bqpwc1bnsrd2nb8dhjv-glibc-2.38-44-getent
+/nix/store/g0l1m2n3o4p5q6r7s8t9u0v1w2x3y4z5-etc
+/nix/store/d8z6m5b3h6p2q8ls9y3xnqg5fzlx0zn2-nixos-system-box-24.05.20240301.3a9c1c2
+`
+	want := map[string]string{
+		"openssl":          "1.1.1w, 3.0.13",
+		"glibc":            "2.38-44",
+		"nixos-system-box": "24.05.20240301.3a9c1c2",
+	}
+	if got := parseClosure(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseClosure() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffPackages(t *testing.T) {
+	got := diffPackages(parseSystemDiff(nvdOutput))
+	want := map[string]string{
+		"firefox":             "123.0",
+		"linux":               "6.6.18",
+		"python3.11-requests": "2.28.2",
+		"openssl":             "3.0.13",
+		"htop":                "3.3.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestPkgMatrix(t *testing.T) {
+	m := newPkgMatrix([]*Status{
+		{hostConfig: hostConfig{Host: "a"}},
+		{hostConfig: hostConfig{Host: "b"}},
+		{hostConfig: hostConfig{Host: "c"}},
+	})
+
+	if !m.set("a", map[string]string{"openssl": "3.0.13", "bash": "5.2p26"}) {
+		t.Error("set() of new host reported no change")
+	}
+	if m.set("a", map[string]string{"openssl": "3.0.13", "bash": "5.2p26"}) {
+		t.Error("set() of the same packages reported a change")
+	}
+	m.set("b", parseClosure("/nix/store/5j3y2dhn5r5bl1ppwxmsxa0qsg6zgqkx-openssl-3.0.13-bin\n"))
+	m.set("c", map[string]string{"bash": "5.2p32"})
+
+	if m.diverged("openssl") {
+		t.Error("openssl diverged, but only its outputs differ")
+	}
+	if !m.diverged("bash") {
+		t.Error("bash didn't diverge")
+	}
+	if got := m.version("c", "openssl"); got != "" {
+		t.Errorf("version() = %q for a missing package", got)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"image/color"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// Colour roles that can be set in the palette section of the config.
+const (
+	roleCurrent   = "current"
+	roleBehind    = "behind"
+	roleAhead     = "ahead"
+	roleDiverged  = "diverged"
+	roleDirty     = "dirty"
+	roleUnknown   = "unknown"
+	roleOffline   = "offline"
+	roleReboot    = "reboot"
+	roleUpdating  = "updating"
+	roleHealthy   = "healthy"
+	roleUnhealthy = "unhealthy"
+	roleBorder    = "border"
+	roleText      = "text"
+)
+
+type paletteConfig struct {
+	Preset  string            `json:"preset"`
+	Variant string            `json:"variant"`
+	Light   map[string]string `json:"light"`
+	Dark    map[string]string `json:"dark"`
+}
+
+type palettePreset struct {
+	light map[string]string
+	dark  map[string]string
+}
+
+var palettePresets = map[string]palettePreset{
+	"default": {
+		light: map[string]string{
+			roleCurrent:   "#92CAFF",
+			roleBehind:    "#FFB347",
+			roleAhead:     "#77DD77",
+			roleDiverged:  "#B19CD9",
+			roleDirty:     "#FDFD96",
+			roleUnknown:   "#FF6961",
+			roleOffline:   "#c1c1c1",
+			roleReboot:    "#DE3163",
+			roleHealthy:   "#77DD77",
+			roleUnhealthy: "#FF6961",
+			roleUpdating:  "#FFFFFF",
+			roleBorder:    "#000000",
+			roleText:      "#000000",
+		},
+		dark: map[string]string{
+			roleCurrent:   "#5AA9F0",
+			roleBehind:    "#E69500",
+			roleAhead:     "#4CAF50",
+			roleDiverged:  "#8E7CC3",
+			roleDirty:     "#D4C94A",
+			roleUnknown:   "#E0474C",
+			roleOffline:   "#6B6B6B",
+			roleReboot:    "#C2185B",
+			roleHealthy:   "#4CAF50",
+			roleUnhealthy: "#E0474C",
+		},
+	},
+	// Okabe & Ito, "Color Universal Design".
+	"okabe-ito": {
+		light: map[string]string{
+			roleCurrent:   "#0072B2",
+			roleBehind:    "#E69F00",
+			roleAhead:     "#009E73",
+			roleDiverged:  "#CC79A7",
+			roleDirty:     "#F0E442",
+			roleUnknown:   "#56B4E9",
+			roleOffline:   "#BBBBBB",
+			roleReboot:    "#D55E00",
+			roleHealthy:   "#009E73",
+			roleUnhealthy: "#D55E00",
+			roleUpdating:  "#FFFFFF",
+			roleBorder:    "#000000",
+			roleText:      "#000000",
+		},
+		dark: map[string]string{
+			roleOffline: "#666666",
+		},
+	},
+	// Paul Tol's "bright" qualitative scheme.
+	"tol": {
+		light: map[string]string{
+			roleCurrent:   "#4477AA",
+			roleBehind:    "#CCBB44",
+			roleAhead:     "#228833",
+			roleDiverged:  "#66CCEE",
+			roleDirty:     "#AA3377",
+			roleUnknown:   "#000000",
+			roleOffline:   "#BBBBBB",
+			roleReboot:    "#EE6677",
+			roleHealthy:   "#228833",
+			roleUnhealthy: "#EE6677",
+			roleUpdating:  "#FFFFFF",
+			roleBorder:    "#000000",
+			roleText:      "#000000",
+		},
+		dark: map[string]string{
+			roleUnknown: "#FFFFFF",
+			roleOffline: "#666666",
+		},
+	},
+}
+
+// palette resolves colour roles for both theme variants.
+type palette struct {
+	variant string
+	light   map[string]color.Color
+	dark    map[string]color.Color
+}
+
+var activePalette = newPalette(paletteConfig{})
+
+func newPalette(cfg paletteConfig) *palette {
+	preset, ok := palettePresets[cfg.Preset]
+	if !ok {
+		if cfg.Preset != "" {
+			log.Printf("unknown palette preset %q, using default", cfg.Preset)
+		}
+		preset = palettePresets["default"]
+	}
+
+	p := &palette{
+		variant: cfg.Variant,
+		light:   make(map[string]color.Color),
+		dark:    make(map[string]color.Color),
+	}
+
+	// Dark falls back to light for anything it doesn't set, and user
+	// overrides win over the preset.
+	for _, layer := range []struct {
+		dst  map[string]color.Color
+		srcs []map[string]string
+	}{
+		{p.light, []map[string]string{preset.light, cfg.Light}},
+		{p.dark, []map[string]string{preset.light, preset.dark, cfg.Light, cfg.Dark}},
+	} {
+		for _, src := range layer.srcs {
+			for role, hex := range src {
+				c, err := parseHexColor(hex)
+				if err != nil {
+					log.Printf("invalid colour %q for %q: %s", hex, role, err)
+					continue
+				}
+				layer.dst[role] = c
+			}
+		}
+	}
+
+	return p
+}
+
+// themeVariant returns the variant forced by the config, or v.
+func (p *palette) themeVariant(v fyne.ThemeVariant) fyne.ThemeVariant {
+	switch p.variant {
+	case "light":
+		return theme.VariantLight
+	case "dark":
+		return theme.VariantDark
+	}
+	return v
+}
+
+func (p *palette) currentVariant() fyne.ThemeVariant {
+	v := theme.VariantLight
+	if a := fyne.CurrentApp(); a != nil {
+		v = a.Settings().ThemeVariant()
+	}
+	return p.themeVariant(v)
+}
+
+func (p *palette) colorFor(role string, v fyne.ThemeVariant) color.Color {
+	colors := p.light
+	if p.themeVariant(v) == theme.VariantDark {
+		colors = p.dark
+	}
+	if c, ok := colors[role]; ok {
+		return c
+	}
+	return color.Gray{Y: 0xc1}
+}
+
+// color looks up role for the variant currently in use.
+func (p *palette) color(role string) color.Color {
+	return p.colorFor(role, p.currentVariant())
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+const defaultRebootTimeout = 10 * time.Minute
+
+// rebootSlack is how long past its deadline we wait on a reboot watch
+// before giving up on it, in case polling has stalled.
+const rebootSlack = time.Minute
+
+var errRebooting = errors.New("already rebooting")
+
+func (x *xinStatus) rebootTimeout() time.Duration {
+	if x.config.RebootTimeout != "" {
+		d, err := time.ParseDuration(x.config.RebootTimeout)
+		if err == nil {
+			return d
+		}
+		log.Printf("invalid reboot_timeout %q: %s", x.config.RebootTimeout, err)
+	}
+	return defaultRebootTimeout
+}
+
+// conflicts reports whether s shares a "don't reboot together" group with
+// any host that is currently rebooting.
+func (x *xinStatus) conflicts(s *Status, inflight map[*Status]bool) bool {
+	for _, group := range x.config.RebootExclusive {
+		member := func(h *Status) bool {
+			for _, name := range group {
+				if name == h.Name || name == h.Host {
+					return true
+				}
+			}
+			return false
+		}
+		if !member(s) {
+			continue
+		}
+		for h := range inflight {
+			if member(h) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rebootWatch tracks a host we've rebooted until it comes back or misses
+// its deadline.
+type rebootWatch struct {
+	since    time.Time
+	deadline time.Time
+	rev      string
+	done     chan error
+}
+
+// claimReboot marks s as about to be rebooted, unless it already is being
+// rebooted. Reboots are started from the card, the tray, schedules and
+// rolling reboots, so this is done under rebootMu.
+func (x *xinStatus) claimReboot(s *Status) error {
+	x.rebootMu.Lock()
+	defer x.rebootMu.Unlock()
+	if s.rebootStarting || s.rebootWatch != nil {
+		return fmt.Errorf("%s: %w", s.PrettyName(), errRebooting)
+	}
+	s.rebootStarting = true
+	return nil
+}
+
+// releaseReboot undoes claimReboot once the reboot has been handed to a
+// watch or given up on.
+func (x *xinStatus) releaseReboot(s *Status) {
+	x.rebootMu.Lock()
+	defer x.rebootMu.Unlock()
+	s.rebootStarting = false
+}
+
+// watchReboot starts tracking s as rebooting since the reboot was sent.
+func (x *xinStatus) watchReboot(s *Status, since time.Time) *rebootWatch {
+	w := &rebootWatch{
+		since:    since,
+		deadline: since.Add(x.rebootTimeout()),
+		rev:      s.report.ConfigurationRevision,
+		done:     make(chan error, 1),
+	}
+	x.rebootMu.Lock()
+	s.rebootWatch = w
+	x.rebootMu.Unlock()
+	s.lost = false
+	return w
+}
+
+// checkReboots resolves reboot watches: hosts that are back with a fresh
+// uptime are confirmed, and hosts past their deadline raise an alert.
+func (x *xinStatus) checkReboots() {
+	now := time.Now()
+	for _, s := range x.config.Statuses {
+		x.rebootMu.Lock()
+		w := s.rebootWatch
+		x.rebootMu.Unlock()
+		if w == nil {
+			continue
+		}
+
+		if s.clientEstablished && s.lastSeen.After(w.since) {
+			up, err := parseUptime(s.report.Uptime)
+			if err == nil && up > now.Sub(w.since) {
+				// Still the pre-reboot status.
+				continue
+			}
+			x.clearRebootWatch(s)
+			if err == nil && s.report.ConfigurationRevision != w.rev {
+				err = fmt.Errorf("%s came back on %.8s instead of %.8s",
+					s.PrettyName(), s.report.ConfigurationRevision, w.rev)
+			}
+			if err != nil {
+				x.alert(fmt.Sprintf("%s rebooted with problems", s.PrettyName()), err.Error())
+			} else {
+				x.Log(fmt.Sprintf("%s is back after %s", s.PrettyName(), now.Sub(w.since).Round(time.Second)))
+			}
+			w.done <- err
+			continue
+		}
+
+		if now.After(w.deadline) {
+			x.clearRebootWatch(s)
+			s.lost = true
+			err := fmt.Errorf("%s didn't come back within %s of rebooting",
+				s.PrettyName(), w.deadline.Sub(w.since))
+			x.alert(fmt.Sprintf("%s is not back", s.PrettyName()), err.Error())
+			w.done <- err
+		}
+	}
+}
+
+func (x *xinStatus) clearRebootWatch(s *Status) {
+	x.rebootMu.Lock()
+	defer x.rebootMu.Unlock()
+	s.rebootWatch = nil
+}
+
+// alert makes sure a problem is noticed even if the window is hidden.
+func (x *xinStatus) alert(title, msg string) {
+	log.Printf("%s: %s", title, msg)
+	if a := fyne.CurrentApp(); a != nil {
+		a.SendNotification(fyne.NewNotification(title, msg))
+	}
+	x.showError(errors.New(msg))
+}
+
+func (x *xinStatus) rebootAndVerify(s *Status) error {
+	w, err := x.reboot(s)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(w.deadline) + rebootSlack)
+	defer timer.Stop()
+	select {
+	case err := <-w.done:
+		if err != nil {
+			return err
+		}
+	case <-timer.C:
+		return fmt.Errorf("%s: gave up waiting for the reboot to be verified", s.PrettyName())
+	}
+	if s.report.NeedsRestart {
+		return fmt.Errorf("%s came back but still needs a restart", s.PrettyName())
+	}
+	return nil
+}
+
+// needingReboot lists the reachable hosts that report needs_restart.
+func (x *xinStatus) needingReboot() []*Status {
+	var hosts []*Status
+	for _, s := range x.config.Statuses {
+		if s.clientEstablished && s.report.NeedsRestart {
+			hosts = append(hosts, s)
+		}
+	}
+	return hosts
+}
+
+// rebootAll reboots hosts at most RebootConcurrency at a time, never
+// rebooting members of the same exclusive group together, and stops
+// starting new reboots as soon as one host fails to come back healthy.
+func (x *xinStatus) rebootAll(hosts []*Status) error {
+	limit := x.config.RebootConcurrency
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		wg       sync.WaitGroup
+		inflight = make(map[*Status]bool)
+		pending  = append([]*Status(nil), hosts...)
+		errs     []error
+	)
+
+	mu.Lock()
+	for len(pending) > 0 && len(errs) == 0 {
+		next := -1
+		if len(inflight) < limit {
+			for i, s := range pending {
+				if !x.conflicts(s, inflight) {
+					next = i
+					break
+				}
+			}
+		}
+		if next < 0 {
+			cond.Wait()
+			continue
+		}
+
+		s := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		inflight[s] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			j := x.jobs.start(s.PrettyName(), actionReboot, false)
+			err := x.rebootAndVerify(s)
+			x.jobs.finish(j, "", err)
+
+			mu.Lock()
+			delete(inflight, s)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			cond.Broadcast()
+			mu.Unlock()
+		}()
+	}
+	skipped := len(pending)
+	mu.Unlock()
+
+	wg.Wait()
+
+	if skipped > 0 {
+		errs = append(errs, fmt.Errorf("%d host(s) not rebooted", skipped))
+	}
+	return errors.Join(errs...)
+}
+
+// confirmRebootAll asks before rebooting every host that needs it.
+func (x *xinStatus) confirmRebootAll() {
+	if x.rollingReboot {
+		dialog.ShowInformation("Reboot", "A rolling reboot is already running.", x.window)
+		return
+	}
+
+	hosts := x.needingReboot()
+	if len(hosts) == 0 {
+		dialog.ShowInformation("Reboot", "No hosts need a reboot.", x.window)
+		return
+	}
+
+	var names []string
+	for _, s := range hosts {
+		names = append(names, s.PrettyName())
+	}
+
+	cnf := dialog.NewConfirm("Confirmation",
+		fmt.Sprintf("Reboot %s?", strings.Join(names, ", ")),
+		func(doit bool) {
+			if !doit {
+				return
+			}
+			x.rollingReboot = true
+			go func() {
+				defer func() { x.rollingReboot = false }()
+				if err := x.rebootAll(hosts); err != nil {
+					x.showError(err)
+				}
+			}()
+		}, x.window)
+	cnf.SetDismissText("Cancel")
+	cnf.SetConfirmText("Ok")
+	cnf.Show()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRebootRefusedWhileRebooting(t *testing.T) {
+	x := &xinStatus{}
+	w := &rebootWatch{done: make(chan error, 1)}
+	s := &Status{hostConfig: hostConfig{Host: "box"}, rebootWatch: w}
+
+	if err := x.rebootAndVerify(s); !errors.Is(err, errRebooting) {
+		t.Fatalf("rebootAndVerify() = %v, want %v", err, errRebooting)
+	}
+	if s.rebootWatch != w {
+		t.Error("the running reboot watch was replaced")
+	}
+}
+
+func TestClaimRebootOnce(t *testing.T) {
+	x := &xinStatus{}
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- x.claimReboot(s)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	claimed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			claimed++
+		case !errors.Is(err, errRebooting):
+			t.Errorf("claimReboot() = %v, want %v", err, errRebooting)
+		}
+	}
+	if claimed != 1 {
+		t.Fatalf("%d claims succeeded, want 1", claimed)
+	}
+
+	x.releaseReboot(s)
+	if err := x.claimReboot(s); err != nil {
+		t.Errorf("claimReboot() after release = %v", err)
+	}
+}
+
+func TestRebootSent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"ok", nil, true},
+		{"dropped", &ssh.ExitMissingError{}, true},
+		{"eof", io.EOF, true},
+		{"exit status", &ssh.ExitError{}, false},
+		{"dial", errors.New("dial tcp: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := rebootSent(tt.err); got != tt.want {
+			t.Errorf("%s: rebootSent(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRebootNotSentLeavesNoWatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	x := &xinStatus{}
+	s := &Status{hostConfig: hostConfig{Host: "box", Port: 22}}
+
+	if err := x.rebootAndVerify(s); err == nil {
+		t.Fatal("rebootAndVerify() succeeded without a connection")
+	}
+	if s.rebootWatch != nil || s.rebootStarting {
+		t.Errorf("reboot left behind watch %v, starting %v", s.rebootWatch, s.rebootStarting)
+	}
+}
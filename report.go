@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// statusSchemaVersion is the newest "xin status" schema we know about.
+// Fields are decoded by name whatever the version, so newer output only
+// earns a warning that some of it may not be understood.
+const statusSchemaVersion = 2
+
+// decodeStatus decodes "xin status" output into v, a pointer to a struct
+// with json tags. Unlike json.Unmarshal it doesn't give up on the first
+// field with an unexpected type: fields that can be converted are, ones
+// that can't are skipped with a warning, and keys v doesn't know about are
+// returned so they can still be shown.
+func decodeStatus(data []byte, v interface{}) (extra map[string]json.RawMessage, warnings []string, err error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	if rv, ok := raw["schema_version"]; ok {
+		var version int
+		if err := json.Unmarshal(rv, &version); err != nil {
+			warnings = append(warnings, fmt.Sprintf("schema_version: %s", err))
+		} else if version > statusSchemaVersion {
+			warnings = append(warnings, fmt.Sprintf("schema version %d is newer than %d, some fields may be missing", version, statusSchemaVersion))
+		}
+	}
+
+	fields := jsonFields(reflect.ValueOf(v).Elem())
+	extra = make(map[string]json.RawMessage)
+	for key, rv := range raw {
+		f, ok := fields[key]
+		if !ok {
+			if key != "schema_version" {
+				extra[key] = rv
+			}
+			continue
+		}
+		if err := decodeLenient(rv, f); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+	sort.Strings(warnings)
+
+	return extra, warnings, nil
+}
+
+// applyReport replaces what we know from the last "xin status" with output.
+// Each report is decoded from scratch so fields a host stops reporting
+// don't linger. It reports whether the warnings changed.
+func (s *Status) applyReport(output []byte) (bool, error) {
+	var rep hostReport
+	extra, warnings, err := decodeStatus(output, &rep)
+	if err != nil {
+		return false, err
+	}
+
+	prev := strings.Join(s.warnings, "\n")
+	s.report = rep
+	s.extra = extra
+	s.warnings = append(s.identityWarnings(), warnings...)
+	return strings.Join(s.warnings, "\n") != prev, nil
+}
+
+// jsonFields maps the json names of the exported fields of v to the fields.
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields
+}
+
+// decodeLenient sets f from raw, converting between strings, numbers and
+// bools where the intent is clear.
+func decodeLenient(raw json.RawMessage, f reflect.Value) error {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+
+	// The common case: the types match.
+	if err := json.Unmarshal(raw, f.Addr().Interface()); err == nil {
+		return nil
+	}
+
+	var val interface{}
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return err
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(displayValue(val))
+		return fmt.Errorf("expected string, got %s", jsonType(val))
+	case reflect.Bool:
+		if s, ok := val.(string); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			f.SetBool(b)
+			return fmt.Errorf("expected bool, got string")
+		}
+		if n, ok := val.(float64); ok {
+			f.SetBool(n != 0)
+			return fmt.Errorf("expected bool, got number")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := val.(string); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetInt(n)
+			return fmt.Errorf("expected number, got string")
+		}
+		if n, ok := val.(float64); ok {
+			f.SetInt(int64(n))
+			return fmt.Errorf("expected integer, got %v", n)
+		}
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			break
+		}
+		var items []string
+		switch val := val.(type) {
+		case string:
+			items = strings.FieldsFunc(val, func(r rune) bool {
+				return r == '\n' || r == ','
+			})
+			for i := range items {
+				items[i] = strings.TrimSpace(items[i])
+			}
+		case []interface{}:
+			for _, item := range val {
+				items = append(items, displayValue(item))
+			}
+		default:
+			return fmt.Errorf("expected list, got %s", jsonType(val))
+		}
+		f.Set(reflect.ValueOf(items))
+		return nil
+	case reflect.Map:
+		if f.Type().Key().Kind() != reflect.String || f.Type().Elem().Kind() != reflect.String {
+			break
+		}
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %s", jsonType(val))
+		}
+		m := make(map[string]string, len(obj))
+		for k, v := range obj {
+			m[k] = displayValue(v)
+		}
+		f.Set(reflect.ValueOf(m))
+		return nil
+	}
+
+	return fmt.Errorf("can't use %s as %s", jsonType(val), f.Type())
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// displayValue renders a decoded JSON value as text for the host table.
+func displayValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, displayValue(item))
+		}
+		return strings.Join(items, "\n")
+	case map[string]interface{}:
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var items []string
+		for _, k := range keys {
+			items = append(items, fmt.Sprintf("%s: %s", k, displayValue(v[k])))
+		}
+		return strings.Join(items, "\n")
+	}
+	return fmt.Sprint(v)
+}
+
+// rawDisplay renders an undecoded JSON value for display.
+func rawDisplay(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	return displayValue(v)
+}
+
+// extraRows are the host table rows for optional and unrecognised fields
+// of the last "xin status" output.
+func (s *Status) extraRows() [][2]string {
+	var rows [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			rows = append(rows, [2]string{label, value})
+		}
+	}
+
+	add("Reported Hostname", s.report.Hostname)
+	add("Kernel", s.report.Kernel)
+	add("Boot Time", s.report.BootTime)
+	add("Failed Units", strings.Join(s.report.FailedUnits, "\n"))
+	var disks []string
+	for mount, usage := range s.report.DiskUsage {
+		disks = append(disks, fmt.Sprintf("%s: %s", mount, usage))
+	}
+	sort.Strings(disks)
+	add("Disk Usage", strings.Join(disks, "\n"))
+
+	var keys []string
+	for k := range s.extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, rawDisplay(s.extra[k]))
+	}
+
+	add("Health", strings.Join(s.health.problems(), "\n"))
+	add("Status Warnings", strings.Join(s.warnings, "\n"))
+
+	return rows
+}
+
+// identityWarnings compares what s reports about itself with how it is
+// configured.
+func (s *Status) identityWarnings() []string {
+	var warnings []string
+	seen := make(map[string]bool)
+	for _, reported := range []string{s.report.Hostname, s.report.Host} {
+		if reported == "" || seen[reported] || s.isNamed(reported) {
+			continue
+		}
+		seen[reported] = true
+		warnings = append(warnings, fmt.Sprintf("reports hostname %q but is configured as %q", reported, s.PrettyName()))
+	}
+	if s.report.Port != 0 && s.report.Port != s.Port {
+		warnings = append(warnings, fmt.Sprintf("reports port %d but is configured with %d", s.report.Port, s.Port))
+	}
+	return warnings
+}
+
+// isNamed reports whether name, ignoring any domain, matches the
+// configured name or host of s.
+func (s *Status) isNamed(name string) bool {
+	short := func(h string) string {
+		h, _, _ = strings.Cut(h, ".")
+		return strings.ToLower(h)
+	}
+	if s.Name != "" && short(name) == short(s.Name) {
+		return true
+	}
+	if net.ParseIP(s.Host) != nil {
+		// Nothing to compare against.
+		return s.Name == ""
+	}
+	return short(name) == short(s.Host)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApplyReportDropsOldFields(t *testing.T) {
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+
+	first := `{"hostname":"box","failed_units":["a.service"],"disk_usage":{"/":"40%","/boot":"90%"},"shiny":1}`
+	if _, err := s.applyReport([]byte(first)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.report.FailedUnits) != 1 || s.report.DiskUsage["/boot"] != "90%" || s.extra["shiny"] == nil {
+		t.Fatalf("first report not applied: %+v, extra %v", s.report, s.extra)
+	}
+
+	second := `{"hostname":"box","disk_usage":{"/":"41%"}}`
+	if _, err := s.applyReport([]byte(second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.report.FailedUnits) != 0 {
+		t.Errorf("failed units = %v, want none", s.report.FailedUnits)
+	}
+	if _, ok := s.report.DiskUsage["/boot"]; ok {
+		t.Errorf("disk usage = %v, /boot should be gone", s.report.DiskUsage)
+	}
+	if s.report.DiskUsage["/"] != "41%" {
+		t.Errorf("disk usage / = %q, want 41%%", s.report.DiskUsage["/"])
+	}
+	if _, ok := s.extra["shiny"]; ok {
+		t.Errorf("extra = %v, shiny should be gone", s.extra)
+	}
+}
+
+func TestApplyReportKeepsLastOnError(t *testing.T) {
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+	if _, err := s.applyReport([]byte(`{"hostname":"box","uptime":"1 day"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.applyReport([]byte(`not json`)); err == nil {
+		t.Fatal("accepted invalid output")
+	}
+	if s.report.Uptime != "1 day" {
+		t.Errorf("uptime = %q, want the last good report", s.report.Uptime)
+	}
+}
+
+func TestApplyReportWarnings(t *testing.T) {
+	s := &Status{hostConfig: hostConfig{Host: "box"}}
+
+	changed, err := s.applyReport([]byte(`{"hostname":"other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(s.warnings) != 1 {
+		t.Fatalf("changed = %v, warnings = %v, want one new warning", changed, s.warnings)
+	}
+
+	changed, err = s.applyReport([]byte(`{"hostname":"other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Errorf("same warnings reported as changed")
+	}
+
+	changed, err = s.applyReport([]byte(`{"hostname":"box"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(s.warnings) != 0 {
+		t.Errorf("changed = %v, warnings = %v, want warnings cleared", changed, s.warnings)
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	type fields struct {
+		Str   string            `json:"str"`
+		Bool  bool              `json:"bool"`
+		Port  int32             `json:"port"`
+		List  []string          `json:"list"`
+		Map   map[string]string `json:"map"`
+		Float float64           `json:"float"`
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		raw  string
+		want fields
+		warn bool
+		err  bool
+	}{
+		{name: "string", key: "str", raw: `"x"`, want: fields{Str: "x"}},
+		{name: "number as string", key: "str", raw: `42`, want: fields{Str: "42"}, warn: true},
+		{name: "bool as string", key: "str", raw: `true`, want: fields{Str: "true"}, warn: true},
+		{name: "bool", key: "bool", raw: `true`, want: fields{Bool: true}},
+		{name: "bool from string", key: "bool", raw: `"true"`, want: fields{Bool: true}, warn: true},
+		{name: "bool from number", key: "bool", raw: `1`, want: fields{Bool: true}, warn: true},
+		{name: "bool from nonsense", key: "bool", raw: `"maybe"`, err: true},
+		{name: "port", key: "port", raw: `22`, want: fields{Port: 22}},
+		{name: "port from string", key: "port", raw: `"2222"`, want: fields{Port: 2222}, warn: true},
+		{name: "port from padded string", key: "port", raw: `" 22 "`, want: fields{Port: 22}, warn: true},
+		{name: "port from fraction", key: "port", raw: `22.5`, want: fields{Port: 22}, warn: true},
+		{name: "port out of range", key: "port", raw: `"99999999999"`, err: true},
+		{name: "port from list", key: "port", raw: `[22]`, err: true},
+		{name: "list", key: "list", raw: `["a","b"]`, want: fields{List: []string{"a", "b"}}},
+		{name: "list from lines", key: "list", raw: `"a.service\nb.service"`, want: fields{List: []string{"a.service", "b.service"}}},
+		{name: "list from commas", key: "list", raw: `"a, b"`, want: fields{List: []string{"a", "b"}}},
+		{name: "list of numbers", key: "list", raw: `[1, 2]`, want: fields{List: []string{"1", "2"}}},
+		{name: "list from object", key: "list", raw: `{"a":1}`, err: true},
+		{name: "map", key: "map", raw: `{"/":"40%"}`, want: fields{Map: map[string]string{"/": "40%"}}},
+		{name: "map of numbers", key: "map", raw: `{"/":40}`, want: fields{Map: map[string]string{"/": "40"}}},
+		{name: "map from string", key: "map", raw: `"/: 40%"`, err: true},
+		{name: "null", key: "list", raw: `null`},
+		{name: "unsupported kind", key: "float", raw: `"fast"`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got fields
+			err := decodeLenient(json.RawMessage(tt.raw), jsonFields(reflect.ValueOf(&got).Elem())[tt.key])
+			switch {
+			case tt.err:
+				if err == nil {
+					t.Errorf("decodeLenient(%s) = %+v, want an error", tt.raw, got)
+				}
+				return
+			case tt.warn && err == nil:
+				t.Errorf("decodeLenient(%s) converted without a warning", tt.raw)
+			case !tt.warn && err != nil:
+				t.Errorf("decodeLenient(%s) = %s", tt.raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeLenient(%s) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStatus(t *testing.T) {
+	out := `{
+		"schema_version": 2,
+		"configurationRevision": "1a2b3c4d",
+		"needs_restart": "false",
+		"port": "2222",
+		"failed_units": "a.service",
+		"secure_boot": true,
+		"zfs": {"pool": "tank", "healthy": true}
+	}`
+
+	var rep hostReport
+	extra, warnings, err := decodeStatus([]byte(out), &rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := hostReport{
+		ConfigurationRevision: "1a2b3c4d",
+		Port:                  2222,
+		FailedUnits:           []string{"a.service"},
+	}
+	if !reflect.DeepEqual(rep, want) {
+		t.Errorf("report = %+v, want %+v", rep, want)
+	}
+
+	wantWarnings := []string{"needs_restart: expected bool, got string", "port: expected number, got string"}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	if len(extra) != 2 || string(extra["secure_boot"]) != "true" ||
+		string(extra["zfs"]) != `{"pool": "tank", "healthy": true}` {
+		t.Errorf("extra = %s", extra)
+	}
+	if _, ok := extra["schema_version"]; ok {
+		t.Error("schema_version kept as an extra field")
+	}
+}
+
+func TestDecodeStatusVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+	}{
+		{"unversioned", `{"kernel":"6.6.18"}`, nil},
+		{"current", `{"schema_version":2,"kernel":"6.6.18"}`, nil},
+		{"newer", `{"schema_version":3,"kernel":"6.6.18"}`, []string{"schema version 3 is newer than 2, some fields may be missing"}},
+		{"invalid", `{"schema_version":"two","kernel":"6.6.18"}`, []string{"schema_version: json: cannot unmarshal string into Go value of type int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rep hostReport
+			_, warnings, err := decodeStatus([]byte(tt.out), &rep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(warnings, tt.want) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.want)
+			}
+			// Fields are decoded whatever the version.
+			if rep.Kernel != "6.6.18" {
+				t.Errorf("kernel = %q", rep.Kernel)
+			}
+		})
+	}
+
+	if _, _, err := decodeStatus([]byte(`["not", "an", "object"]`), &hostReport{}); err == nil {
+		t.Error("decodeStatus() accepted a list")
+	}
+}
@@ -77,12 +77,43 @@ func (f *Feed) LatestHash() (*commit, error) {
 	}
 
 	hash := hashParts[1]
+	subject, _, _ := strings.Cut(cmitMsg, "\n")
 
 	return &commit{
 		hash: hash,
 		// TODO: use x/html to pull out the info?
 		message: cmitMsg,
+		subject: subject,
+		author:  f.Entry[0].Author.Name,
 		//message: html.UnescapeString(f.Entry[0].Content.Text),
 		date: f.Entry[0].Updated,
 	}, nil
 }
+
+// Hashes returns the commit hashes in the feed, newest first.
+func (f *Feed) Hashes() []string {
+	var hashes []string
+	for _, e := range f.Entry {
+		if i := strings.LastIndex(e.ID, "/"); i >= 0 && i < len(e.ID)-1 {
+			hashes = append(hashes, e.ID[i+1:])
+		}
+	}
+	return hashes
+}
+
+// placeInFeed works out the state of s from the upstream feed when there is
+// no local repository. Revisions listed in the feed are behind by their
+// position in it. Anything else may be older than the feed goes back, ahead
+// of it or from a fork, so it is left unknown; auto-update never acts on
+// unknown revisions.
+func (x *xinStatus) placeInFeed(s *Status, rev string) {
+	for i, h := range x.feedHashes {
+		if i > 0 && h == rev {
+			s.state = stateBehind
+			s.behindNote = fmt.Sprintf("%d commits behind", i)
+			return
+		}
+	}
+	s.state = stateUnknownRev
+	s.behindNote = "not in the upstream feed, won't auto-update"
+}
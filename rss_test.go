@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/" xml:lang="en-US">
+  <id>tag:github.com,2008:/qbit/xin/commits/main</id>
+  <title>Recent Commits to xin:main</title>
+  <updated>2024-03-02T10:00:00Z</updated>
+  <entry>
+    <id>tag:github.com,2008:Grit::Commit/cccccccccccccccccccccccccccccccccccccccc</id>
+    <title>flake.lock: update</title>
+    <updated>2024-03-02T10:00:00Z</updated>
+    <author><name>qbit</name></author>
+    <content type="html">&lt;pre&gt;flake.lock: update
+&lt;/pre&gt;</content>
+  </entry>
+  <entry>
+    <id>tag:github.com,2008:Grit::Commit/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb</id>
+    <title>box: enable ssh</title>
+    <updated>2024-03-01T10:00:00Z</updated>
+    <author><name>qbit</name></author>
+    <content type="html">&lt;pre&gt;box: enable ssh&lt;/pre&gt;</content>
+  </entry>
+  <entry>
+    <id>tag:github.com,2008:Grit::Commit/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa</id>
+    <title>initial</title>
+    <updated>2024-02-28T10:00:00Z</updated>
+    <author><name>qbit</name></author>
+    <content type="html">&lt;pre&gt;initial&lt;/pre&gt;</content>
+  </entry>
+</feed>`
+
+func decodeTestFeed(t *testing.T) *Feed {
+	t.Helper()
+	f := &Feed{}
+	if err := xml.Unmarshal([]byte(testFeed), f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFeedHashes(t *testing.T) {
+	f := decodeTestFeed(t)
+
+	want := []string{
+		"cccccccccccccccccccccccccccccccccccccccc",
+		"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+	}
+	if got := f.Hashes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashes() = %v, want %v", got, want)
+	}
+
+	latest, err := f.LatestHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.hash != want[0] || latest.subject != "flake.lock: update" {
+		t.Errorf("LatestHash() = %s %q", latest.hash, latest.subject)
+	}
+}
+
+func TestPlaceInFeed(t *testing.T) {
+	x := &xinStatus{feedHashes: decodeTestFeed(t).Hashes()}
+
+	tests := []struct {
+		rev  string
+		want hostState
+		note string
+	}{
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", stateBehind, "1 commits behind"},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", stateBehind, "2 commits behind"},
+		{"dddddddddddddddddddddddddddddddddddddddd", stateUnknownRev, "not in the upstream feed, won't auto-update"},
+		{"", stateUnknownRev, "not in the upstream feed, won't auto-update"},
+	}
+	for _, tt := range tests {
+		s := &Status{}
+		x.placeInFeed(s, tt.rev)
+		if s.state != tt.want || s.behindNote != tt.note {
+			t.Errorf("placeInFeed(%.8q) = %v %q, want %v %q", tt.rev, s.state, s.behindNote, tt.want, tt.note)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	actionReboot = "reboot"
+	actionUpdate = "update"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maintWindow is a recurring weekly window such as "Sat,Sun 02:00-04:00"
+// or "daily 01:30-03:00". Windows may wrap past midnight.
+type maintWindow struct {
+	spec  string
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWindow(spec string) (*maintWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid maintenance window %q", spec)
+	}
+
+	w := &maintWindow{spec: spec}
+	if strings.EqualFold(fields[0], "daily") {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else {
+		for _, d := range strings.Split(fields[0], ",") {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q in maintenance window %q", d, spec)
+			}
+			w.days[wd] = true
+		}
+	}
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid time range in maintenance window %q", spec)
+	}
+	var err error
+	if w.start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(end); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// onDay returns the wall clock time d after midnight on the day of t.
+// Going by the clock rather than adding d to midnight keeps windows at
+// the right time on days when daylight saving time starts or ends.
+func onDay(t time.Time, d time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(),
+		int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, t.Location())
+}
+
+// bounds returns when the window opening on day opens and closes.
+func (w *maintWindow) bounds(day time.Time) (time.Time, time.Time) {
+	open := onDay(day, w.start)
+	if w.end > w.start {
+		return open, onDay(day, w.end)
+	}
+	return open, onDay(day.AddDate(0, 0, 1), w.end)
+}
+
+// openedAt returns when the window containing t opened, if t falls in one.
+func (w *maintWindow) openedAt(t time.Time) (time.Time, bool) {
+	// Check today's window and, for windows that wrap midnight, yesterday's.
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		open, close := w.bounds(day)
+		if !t.Before(open) && t.Before(close) {
+			return open, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *maintWindow) contains(t time.Time) bool {
+	_, ok := w.openedAt(t)
+	return ok
+}
+
+// next returns the first time after t that the window opens.
+func (w *maintWindow) next(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		if open := onDay(day, w.start); open.After(t) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+// hostWindow returns the maintenance window that applies to s, if any.
+func (x *xinStatus) hostWindow(s *Status) *maintWindow {
+	spec := s.MaintenanceWindow
+	if spec == "" && s.Group != "" {
+		spec = x.config.MaintenanceWindows[s.Group]
+	}
+	if spec == "" {
+		return nil
+	}
+	w, err := parseWindow(spec)
+	if err != nil {
+		log.Printf("%s: %s", s.PrettyName(), err)
+		return nil
+	}
+	return w
+}
+
+// scheduledAction is a reboot or update waiting to run. Recurring actions
+// are re-armed for the host's next maintenance window after they run.
+type scheduledAction struct {
+	Host      string    `json:"host"`
+	Action    string    `json:"action"`
+	At        time.Time `json:"at"`
+	Recurring bool      `json:"recurring"`
+}
+
+func (a *scheduledAction) String() string {
+	s := fmt.Sprintf("%s at %s", a.Action, a.At.Format("Mon Jan 2 15:04"))
+	if a.Recurring {
+		s += " (recurring)"
+	}
+	return s
+}
+
+type scheduler struct {
+	mu      sync.Mutex
+	file    string
+	actions []*scheduledAction
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		file: path.Clean(path.Join(os.Getenv("HOME"), ".xin-schedule.json")),
+	}
+}
+
+func (sc *scheduler) load() error {
+	data, err := os.ReadFile(sc.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return json.Unmarshal(data, &sc.actions)
+}
+
+// save must be called with sc.mu held.
+func (sc *scheduler) save() error {
+	data, err := json.MarshalIndent(sc.actions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sc.file, data, 0600)
+}
+
+func (sc *scheduler) add(a *scheduledAction) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.actions = append(sc.actions, a)
+	return sc.save()
+}
+
+func (sc *scheduler) clear(host string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var keep []*scheduledAction
+	for _, a := range sc.actions {
+		if a.Host != host {
+			keep = append(keep, a)
+		}
+	}
+	sc.actions = keep
+	return sc.save()
+}
+
+func (sc *scheduler) pending(host string) []*scheduledAction {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var found []*scheduledAction
+	for _, a := range sc.actions {
+		if a.Host == host {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// runSchedules starts any scheduled actions that have come due.
+// Nothing runs while automatic actions are paused; anything that comes due
+// in the meantime is left for missed to judge once they're resumed.
+func (x *xinStatus) runSchedules() {
+	defer func() {
+		for _, s := range x.config.Statuses {
+			x.updateScheduleNote(s)
+		}
+	}()
+	if x.paused() {
+		return
+	}
+
+	sc := x.schedules
+	now := time.Now()
+
+	sc.mu.Lock()
+	var due []*scheduledAction
+	var keep []*scheduledAction
+	changed := false
+	for _, a := range sc.actions {
+		if a.At.After(now) {
+			keep = append(keep, a)
+			continue
+		}
+		if s := x.findHost(a.Host); s != nil && !s.clientEstablished && !x.missed(a, now) {
+			// Wait for the host to come back; missed drops the action
+			// once it's too late.
+			keep = append(keep, a)
+			continue
+		}
+		changed = true
+		if x.missed(a, now) {
+			log.Printf("skipping %s of %s: it was due at %s and its window has passed",
+				a.Action, a.Host, a.At.Format("Mon Jan 2 15:04"))
+		} else {
+			due = append(due, a)
+		}
+		if a.Recurring {
+			if s := x.findHost(a.Host); s != nil {
+				if w := x.hostWindow(s); w != nil {
+					next := *a
+					next.At = w.next(now)
+					keep = append(keep, &next)
+				}
+			}
+		}
+	}
+	sc.actions = keep
+	if changed {
+		if err := sc.save(); err != nil {
+			log.Println(err)
+		}
+	}
+	sc.mu.Unlock()
+
+	for _, a := range due {
+		s := x.findHost(a.Host)
+		if s == nil {
+			log.Printf("scheduled %s for unknown host %q", a.Action, a.Host)
+			continue
+		}
+		switch a.Action {
+		case actionReboot:
+			go x.runJob(s, actionReboot, true, x.rebootJob)
+		case actionUpdate:
+			if reason := x.pins.reason(s.Host); reason != "" {
+				log.Printf("skipping scheduled update of %s: automatic updates held: %s", s.PrettyName(), reason)
+				continue
+			}
+			go x.runJob(s, actionUpdate, true, x.update)
+		}
+	}
+}
+
+// scheduleGrace is how late a one-off action may still run, e.g. because
+// xintray was busy or only just started.
+const scheduleGrace = 15 * time.Minute
+
+// missed reports whether a due action is too late to run. Actions found
+// overdue, typically because xintray wasn't running when they came due,
+// only run if the host's maintenance window is still open.
+func (x *xinStatus) missed(a *scheduledAction, now time.Time) bool {
+	if now.Sub(a.At) <= scheduleGrace {
+		return false
+	}
+	s := x.findHost(a.Host)
+	if s == nil {
+		return false
+	}
+	w := x.hostWindow(s)
+	return w == nil || !w.contains(now)
+}
+
+func (x *xinStatus) updateScheduleNote(s *Status) {
+	var notes []string
+	for _, a := range x.schedules.pending(s.Host) {
+		notes = append(notes, a.String())
+	}
+	if reason := x.pins.reason(s.Host); reason != "" {
+		notes = append(notes, "automatic updates held: "+reason)
+	}
+	s.scheduleNote = strings.Join(notes, "\n")
+}
+
+const (
+	whenNextWindow = "Next maintenance window"
+	whenRecurring  = "Every maintenance window"
+	whenAt         = "At a specific time"
+	whenClear      = "Clear scheduled actions"
+)
+
+// showScheduleDialog lets the user schedule an action for each of hosts.
+func (x *xinStatus) showScheduleDialog(title string, hosts []*Status) {
+	action := widget.NewSelect([]string{actionReboot, actionUpdate}, nil)
+	action.SetSelected(actionReboot)
+
+	at := widget.NewEntry()
+	at.SetPlaceHolder("2006-01-02 15:04")
+	at.Disable()
+
+	when := widget.NewSelect([]string{whenNextWindow, whenRecurring, whenAt, whenClear}, func(v string) {
+		if v == whenAt {
+			at.Enable()
+		} else {
+			at.Disable()
+		}
+	})
+	when.SetSelected(whenNextWindow)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Action", action),
+		widget.NewFormItem("When", when),
+		widget.NewFormItem("Time", at),
+	}
+
+	dialog.ShowForm(title, "Schedule", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		for _, s := range hosts {
+			if err := x.schedule(s, action.Selected, when.Selected, at.Text); err != nil {
+				x.showError(err)
+			}
+			x.updateScheduleNote(s)
+		}
+		x.reloadBindings()
+	}, x.window)
+}
+
+func (x *xinStatus) schedule(s *Status, action, when, at string) error {
+	if when == whenClear {
+		return x.schedules.clear(s.Host)
+	}
+
+	a := &scheduledAction{
+		Host:   s.Host,
+		Action: action,
+	}
+
+	switch when {
+	case whenAt:
+		t, err := time.ParseInLocation("2006-01-02 15:04", at, time.Local)
+		if err != nil {
+			return err
+		}
+		a.At = t
+	default:
+		w := x.hostWindow(s)
+		if w == nil {
+			return fmt.Errorf("%s has no maintenance window configured", s.PrettyName())
+		}
+		a.At = w.next(time.Now())
+		a.Recurring = when == whenRecurring
+	}
+
+	return x.schedules.add(a)
+}
+
+func (x *xinStatus) groups() []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, s := range x.config.Statuses {
+		if s.Group != "" && !seen[s.Group] {
+			seen[s.Group] = true
+			groups = append(groups, s.Group)
+		}
+	}
+	return groups
+}
+
+// showGroupScheduleDialog picks a group and then schedules for all of it.
+func (x *xinStatus) showGroupScheduleDialog() {
+	groups := x.groups()
+	if len(groups) == 0 {
+		dialog.ShowInformation("Schedule", "No host groups are configured.", x.window)
+		return
+	}
+
+	sel := widget.NewSelect(groups, nil)
+	sel.SetSelected(groups[0])
+	dialog.ShowForm("Schedule group", "Next", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Group", sel)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			var hosts []*Status
+			for _, s := range x.config.Statuses {
+				if s.Group == sel.Selected {
+					hosts = append(hosts, s)
+				}
+			}
+			x.showScheduleDialog(fmt.Sprintf("Schedule %s", sel.Selected), hosts)
+		}, x.window)
+}
+
+func (x *xinStatus) reloadBindings() {
+	fyne.Do(func() {
+		for _, s := range x.boundStrings {
+			s.Reload()
+		}
+		for _, s := range x.boundBools {
+			s.Reload()
+		}
+	})
+}
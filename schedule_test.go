@@ -0,0 +1,218 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestMissed(t *testing.T) {
+	x := &xinStatus{
+		config: Config{
+			Statuses: []*Status{
+				{hostConfig: hostConfig{Host: "windowed", MaintenanceWindow: "daily 02:00-04:00"}},
+				{hostConfig: hostConfig{Host: "plain"}},
+			},
+		},
+	}
+
+	day := time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	tests := []struct {
+		name string
+		host string
+		due  time.Time
+		now  time.Time
+		want bool
+	}{
+		{"on time", "plain", at(2, 0), at(2, 0), false},
+		{"within grace", "plain", at(2, 0), at(2, 10), false},
+		{"one-off past grace", "plain", at(2, 0), at(9, 0), true},
+		{"window still open", "windowed", at(2, 0), at(3, 30), false},
+		{"window closed", "windowed", at(2, 0), at(10, 0), true},
+		{"unknown host", "nobody", at(2, 0), at(10, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &scheduledAction{Host: tt.host, Action: actionReboot, At: tt.due}
+			if got := x.missed(a, tt.now); got != tt.want {
+				t.Errorf("missed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSchedulesWaitsForOfflineHost(t *testing.T) {
+	sc := newScheduler()
+	sc.file = filepath.Join(t.TempDir(), "schedule.json")
+	sc.actions = []*scheduledAction{
+		{Host: "box", Action: actionReboot, At: time.Now().Add(-time.Minute)},
+	}
+	x := &xinStatus{
+		schedules: sc,
+		config: Config{
+			Statuses: []*Status{{hostConfig: hostConfig{Host: "box"}}},
+		},
+	}
+
+	x.runSchedules()
+
+	if got := sc.pending("box"); len(got) != 1 {
+		t.Fatalf("pending = %v, want the action kept until box is back", got)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec  string
+		days  []time.Weekday
+		start time.Duration
+		end   time.Duration
+	}{
+		{"Sat,Sun 02:00-04:00", []time.Weekday{time.Saturday, time.Sunday}, 2 * time.Hour, 4 * time.Hour},
+		{"daily 01:30-03:00", []time.Weekday{0, 1, 2, 3, 4, 5, 6}, 90 * time.Minute, 3 * time.Hour},
+		{"FRI 23:00-01:00", []time.Weekday{time.Friday}, 23 * time.Hour, time.Hour},
+		{"mon,wed,fri 00:00-00:30", []time.Weekday{time.Monday, time.Wednesday, time.Friday}, 0, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		w, err := parseWindow(tt.spec)
+		if err != nil {
+			t.Errorf("parseWindow(%q): %s", tt.spec, err)
+			continue
+		}
+		var days [7]bool
+		for _, d := range tt.days {
+			days[d] = true
+		}
+		if w.days != days || w.start != tt.start || w.end != tt.end {
+			t.Errorf("parseWindow(%q) = %v %s-%s, want %v %s-%s",
+				tt.spec, w.days, w.start, w.end, days, tt.start, tt.end)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"sat",
+		"sat 01:00",
+		"funday 01:00-02:00",
+		"sat 0100-0200",
+		"sat 25:00-26:00",
+		"sat 01:00-02:00 extra",
+	} {
+		if _, err := parseWindow(spec); err == nil {
+			t.Errorf("parseWindow(%q) succeeded", spec)
+		}
+	}
+}
+
+func mustWindow(t *testing.T, spec string) *maintWindow {
+	t.Helper()
+	w, err := parseWindow(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestOpenedAt(t *testing.T) {
+	// 2024-03-01 is a Friday.
+	fri := func(h, m int) time.Time { return time.Date(2024, 3, 1, h, m, 0, 0, time.UTC) }
+	sat := func(h, m int) time.Time { return time.Date(2024, 3, 2, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		spec   string
+		t      time.Time
+		opened time.Time
+		ok     bool
+	}{
+		{"fri 02:00-04:00", fri(2, 0), fri(2, 0), true},
+		{"fri 02:00-04:00", fri(3, 59), fri(2, 0), true},
+		{"fri 02:00-04:00", fri(4, 0), time.Time{}, false},
+		{"fri 02:00-04:00", fri(1, 59), time.Time{}, false},
+		{"fri 02:00-04:00", sat(3, 0), time.Time{}, false},
+		// Wrapping past midnight belongs to the day the window opened.
+		{"fri 23:00-01:00", fri(23, 30), fri(23, 0), true},
+		{"fri 23:00-01:00", sat(0, 30), fri(23, 0), true},
+		{"fri 23:00-01:00", sat(1, 0), time.Time{}, false},
+		{"fri 23:00-01:00", sat(23, 30), time.Time{}, false},
+		{"sat 23:00-01:00", sat(0, 30), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		opened, ok := mustWindow(t, tt.spec).openedAt(tt.t)
+		if !opened.Equal(tt.opened) || ok != tt.ok {
+			t.Errorf("%q openedAt(%s) = %s, %v, want %s, %v",
+				tt.spec, tt.t.Format("Mon 15:04"), opened, ok, tt.opened, tt.ok)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	fri := func(h, m int) time.Time { return time.Date(2024, 3, 1, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		spec string
+		t    time.Time
+		want time.Time
+	}{
+		{"fri 23:00-01:00", fri(12, 0), fri(23, 0)},
+		{"fri 23:00-01:00", fri(23, 0), fri(23, 0).AddDate(0, 0, 7)},
+		{"fri 23:00-01:00", fri(23, 30), fri(23, 0).AddDate(0, 0, 7)},
+		{"sat,sun 02:00-04:00", fri(12, 0), time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"daily 02:00-04:00", fri(3, 0), time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := mustWindow(t, tt.spec).next(tt.t); !got.Equal(tt.want) {
+			t.Errorf("%q next(%s) = %s, want %s", tt.spec, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestWindowDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, h, m int) time.Time {
+		return time.Date(2024, month, day, h, m, 0, 0, berlin)
+	}
+
+	// Clocks went back from 03:00 to 02:00 on 2024-10-27.
+	w := mustWindow(t, "daily 03:00-05:00")
+	next := w.next(at(time.October, 27, 0, 0))
+	if h, m, _ := next.Clock(); h != 3 || m != 0 || next.Day() != 27 {
+		t.Errorf("next() = %s, want 03:00 on the 27th", next)
+	}
+	if w.contains(at(time.October, 27, 2, 30)) {
+		t.Error("window open at 02:30 on the day clocks went back")
+	}
+	if !w.contains(at(time.October, 27, 4, 30)) {
+		t.Error("window closed at 04:30 on the day clocks went back")
+	}
+
+	// Clocks went forward from 02:00 to 03:00 on 2024-03-31.
+	w = mustWindow(t, "daily 01:00-04:00")
+	if !w.contains(at(time.March, 31, 3, 30)) {
+		t.Error("window closed at 03:30 on the day clocks went forward")
+	}
+	if w.contains(at(time.March, 31, 4, 30)) {
+		t.Error("window open at 04:30 on the day clocks went forward")
+	}
+	opened, ok := w.openedAt(at(time.March, 31, 3, 30))
+	if h, _, _ := opened.Clock(); !ok || h != 1 {
+		t.Errorf("openedAt() = %s, %v, want 01:00", opened, ok)
+	}
+
+	// A window wrapping midnight across the change.
+	w = mustWindow(t, "sat 23:00-04:00")
+	if !w.contains(at(time.October, 27, 3, 30)) {
+		t.Error("wrapping window closed at 03:30 on the day clocks went back")
+	}
+	if w.contains(at(time.October, 27, 4, 30)) {
+		t.Error("wrapping window open at 04:30 on the day clocks went back")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+)
+
+type hostState int
+
+const (
+	stateOffline hostState = iota
+	stateCurrent
+	stateBehind
+	stateAhead
+	stateDiverged
+	stateDirty
+	stateUnknownRev
+	stateNoReturn
+)
+
+func (h hostState) String() string {
+	switch h {
+	case stateCurrent:
+		return "up to date"
+	case stateBehind:
+		return "behind upstream"
+	case stateAhead:
+		return "ahead of upstream"
+	case stateDiverged:
+		return "diverged from upstream"
+	case stateDirty:
+		return "dirty tree"
+	case stateUnknownRev:
+		return "unknown revision"
+	case stateNoReturn:
+		return "didn't return from reboot"
+	default:
+		return "offline"
+	}
+}
+
+func (h hostState) role() string {
+	switch h {
+	case stateCurrent:
+		return roleCurrent
+	case stateBehind:
+		return roleBehind
+	case stateAhead:
+		return roleAhead
+	case stateDiverged:
+		return roleDiverged
+	case stateDirty:
+		return roleDirty
+	case stateUnknownRev:
+		return roleUnknown
+	case stateNoReturn:
+		return roleReboot
+	default:
+		return roleOffline
+	}
+}
+
+func (h hostState) color() color.Color {
+	return activePalette.color(h.role())
+}
+
+// setSubtitle shows text under the title of the card of s, coloured for
+// role. The card's own subtitle always uses the theme's text colour, so
+// this is a line of its own.
+func (s *Status) setSubtitle(text, role string) {
+	fyne.Do(func() {
+		s.subtitle.Text = text
+		s.subtitle.Color = activePalette.color(role)
+		if text == "" {
+			s.subtitle.Hide()
+		} else {
+			s.subtitle.Show()
+		}
+		s.subtitle.Refresh()
+	})
+}
+
+// stateBadge is the coloured state indicator shown at the top of each card.
+type stateBadge struct {
+	bg   *canvas.Rectangle
+	text *canvas.Text
+	box  *fyne.Container
+}
+
+func newStateBadge() *stateBadge {
+	b := &stateBadge{
+		bg:   canvas.NewRectangle(stateOffline.color()),
+		text: canvas.NewText(stateOffline.String(), activePalette.color(roleText)),
+	}
+	b.bg.CornerRadius = 4
+	b.text.Alignment = fyne.TextAlignCenter
+	b.text.TextStyle = fyne.TextStyle{Bold: true}
+	b.box = container.NewStack(b.bg, container.NewPadded(b.text))
+	return b
+}
+
+func (b *stateBadge) set(h hostState) {
+	b.show(h.String(), h.color())
+}
+
+func (b *stateBadge) setHealth(h *hostHealth) {
+	b.show(h.String(), activePalette.color(h.state().role()))
+}
+
+func (b *stateBadge) show(text string, bg color.Color) {
+	b.bg.FillColor = bg
+	b.text.Color = activePalette.color(roleText)
+	b.text.Text = text
+	b.box.Refresh()
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+type changeKind int
+
+const (
+	changeUpgraded changeKind = iota
+	changeDowngraded
+	changeChanged
+	changeAdded
+	changeRemoved
+)
+
+func (k changeKind) String() string {
+	switch k {
+	case changeUpgraded:
+		return "upgraded"
+	case changeDowngraded:
+		return "downgraded"
+	case changeAdded:
+		return "added"
+	case changeRemoved:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+func (k changeKind) symbol() string {
+	switch k {
+	case changeUpgraded:
+		return "↑"
+	case changeDowngraded:
+		return "↓"
+	case changeAdded:
+		return "+"
+	case changeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// pkgChange is a single package difference between two system closures.
+type pkgChange struct {
+	name string
+	kind changeKind
+	old  string
+	new  string
+	size string
+}
+
+func (c pkgChange) String() string {
+	var s string
+	switch c.kind {
+	case changeAdded:
+		s = fmt.Sprintf("%s %s %s", c.kind.symbol(), c.name, c.new)
+	case changeRemoved:
+		s = fmt.Sprintf("%s %s %s", c.kind.symbol(), c.name, c.old)
+	default:
+		s = fmt.Sprintf("%s %s %s → %s", c.kind.symbol(), c.name, c.old, c.new)
+		if c.old == "" && c.new == "" {
+			s = fmt.Sprintf("%s %s", c.kind.symbol(), c.name)
+		}
+	}
+	if c.size != "" {
+		s += fmt.Sprintf(" (%s)", c.size)
+	}
+	return s
+}
+
+// systemDiff is the parsed form of the system_diff reported by xin, which
+// is either nvd output or "nix store diff-closures" output.
+type systemDiff struct {
+	changes       []pkgChange
+	closureBefore int
+	closureAfter  int
+	diskDelta     string
+}
+
+var (
+	ansiEscape  = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	nvdLine     = regexp.MustCompile(`^\[([A-Z])[^\]]*\]\s+#\d+\s+(\S+)\s+(.*)$`)
+	nvdClosure  = regexp.MustCompile(`^Closure size: (\d+) -> (\d+).*disk usage ([+-]?[\d.]+\s*\S+?)\)?\.?$`)
+	closureLine = regexp.MustCompile(`^(\S+): (.*?)(?:,\s*)?([+-][\d.]+ [KMGT]?i?B)?$`)
+)
+
+func parseSystemDiff(raw string) *systemDiff {
+	d := &systemDiff{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(raw, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if m := nvdClosure.FindStringSubmatch(line); m != nil {
+			d.closureBefore, _ = strconv.Atoi(m[1])
+			d.closureAfter, _ = strconv.Atoi(m[2])
+			d.diskDelta = m[3]
+			continue
+		}
+
+		if m := nvdLine.FindStringSubmatch(line); m != nil {
+			d.changes = append(d.changes, parseNvdChange(m[1], m[2], m[3]))
+			continue
+		}
+
+		if strings.HasPrefix(line, "<<<") || strings.HasPrefix(line, ">>>") || strings.HasSuffix(line, ":") {
+			continue
+		}
+
+		if m := closureLine.FindStringSubmatch(line); m != nil {
+			if c, ok := parseClosureChange(m[1], m[2], m[3]); ok {
+				d.changes = append(d.changes, c)
+			}
+		}
+	}
+	return d
+}
+
+func parseNvdChange(flag, name, versions string) pkgChange {
+	c := pkgChange{name: name}
+	old, new, ok := strings.Cut(versions, " -> ")
+	switch flag {
+	case "A":
+		c.kind = changeAdded
+		c.new = strings.TrimSpace(versions)
+	case "R":
+		c.kind = changeRemoved
+		c.old = strings.TrimSpace(versions)
+	case "U":
+		c.kind = changeUpgraded
+	case "D":
+		c.kind = changeDowngraded
+	default:
+		c.kind = changeChanged
+	}
+	if ok {
+		c.old, c.new = strings.TrimSpace(old), strings.TrimSpace(new)
+	}
+	return c
+}
+
+func parseClosureChange(name, versions, size string) (pkgChange, bool) {
+	c := pkgChange{name: name, size: size}
+	old, new, ok := strings.Cut(versions, " → ")
+	if !ok {
+		// Size only changes don't tell us anything about versions.
+		if versions == "" && size != "" {
+			c.kind = changeChanged
+			return c, true
+		}
+		return c, false
+	}
+	c.old, c.new = strings.TrimSpace(old), strings.TrimSpace(new)
+	switch {
+	case c.old == "∅" || c.old == "ε":
+		c.old = ""
+		c.kind = changeAdded
+	case c.new == "∅" || c.new == "ε":
+		c.new = ""
+		c.kind = changeRemoved
+	case compareVersions(c.old, c.new) < 0:
+		c.kind = changeUpgraded
+	case compareVersions(c.old, c.new) > 0:
+		c.kind = changeDowngraded
+	default:
+		c.kind = changeChanged
+	}
+	return c, true
+}
+
+// compareVersions compares dotted versions numerically where it can,
+// roughly like nix's builtins.compareVersions.
+func compareVersions(a, b string) int {
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool {
+			return r == '.' || r == '-'
+		})
+	}
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func (d *systemDiff) summary() string {
+	counts := make(map[changeKind]int)
+	for _, c := range d.changes {
+		counts[c.kind]++
+	}
+	var parts []string
+	for _, k := range []changeKind{changeUpgraded, changeDowngraded, changeAdded, changeRemoved, changeChanged} {
+		if counts[k] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[k], k))
+		}
+	}
+	if d.closureBefore > 0 || d.closureAfter > 0 {
+		parts = append(parts, fmt.Sprintf("closure %d → %d paths", d.closureBefore, d.closureAfter))
+	}
+	if d.diskDelta != "" {
+		parts = append(parts, d.diskDelta)
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+const (
+	sortByName = "Name"
+	sortByKind = "Change"
+	sortBySize = "Size"
+)
+
+// diffView shows a system diff as a searchable, sortable list with the
+// option of falling back to the raw text.
+type diffView struct {
+	raw     string
+	diff    *systemDiff
+	shown   []pkgChange
+	search  *widget.Entry
+	sortBy  *widget.Select
+	rawMode *widget.Check
+	summary *widget.Label
+	list    *widget.List
+	rawText *widget.Label
+	rawBox  *container.Scroll
+	box     *fyne.Container
+}
+
+func newDiffView() *diffView {
+	v := &diffView{
+		diff:    &systemDiff{},
+		summary: widget.NewLabel(""),
+		rawText: widget.NewLabel(""),
+	}
+
+	v.search = widget.NewEntry()
+	v.search.SetPlaceHolder("Search packages")
+	v.search.OnChanged = func(string) { v.filter() }
+
+	v.sortBy = widget.NewSelect([]string{sortByName, sortByKind, sortBySize}, func(string) { v.filter() })
+	v.sortBy.SetSelected(sortByKind)
+
+	v.list = widget.NewList(
+		func() int { return len(v.shown) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(v.shown[i].String())
+		},
+	)
+
+	v.rawBox = container.NewVScroll(v.rawText)
+	v.rawBox.Hide()
+
+	v.rawMode = widget.NewCheck("Raw", func(raw bool) {
+		if raw {
+			v.list.Hide()
+			v.rawBox.Show()
+		} else {
+			v.rawBox.Hide()
+			v.list.Show()
+		}
+	})
+
+	top := container.NewVBox(
+		v.summary,
+		container.NewBorder(nil, nil, nil, container.NewHBox(v.sortBy, v.rawMode), v.search),
+	)
+	v.box = container.NewBorder(top, nil, nil, nil, container.NewStack(v.list, v.rawBox))
+
+	return v
+}
+
+// set replaces the diff being shown. It must be called on the UI thread.
+func (v *diffView) set(raw string) {
+	if raw == v.raw {
+		return
+	}
+	v.raw = raw
+	v.diff = parseSystemDiff(raw)
+	v.summary.SetText(v.diff.summary())
+	v.rawText.SetText(raw)
+	v.filter()
+}
+
+func (v *diffView) filter() {
+	if v.list == nil || v.diff == nil {
+		return
+	}
+
+	q := strings.ToLower(v.search.Text)
+	v.shown = v.shown[:0]
+	for _, c := range v.diff.changes {
+		if q == "" || strings.Contains(strings.ToLower(c.name), q) {
+			v.shown = append(v.shown, c)
+		}
+	}
+
+	switch v.sortBy.Selected {
+	case sortByName:
+		sort.SliceStable(v.shown, func(i, j int) bool {
+			return v.shown[i].name < v.shown[j].name
+		})
+	case sortBySize:
+		sort.SliceStable(v.shown, func(i, j int) bool {
+			return sizeBytes(v.shown[i].size) > sizeBytes(v.shown[j].size)
+		})
+	default:
+		sort.SliceStable(v.shown, func(i, j int) bool {
+			if v.shown[i].kind != v.shown[j].kind {
+				return v.shown[i].kind < v.shown[j].kind
+			}
+			return v.shown[i].name < v.shown[j].name
+		})
+	}
+
+	v.list.Refresh()
+}
+
+// sizeBytes turns "+1.5 MiB" into a magnitude in bytes for sorting.
+func sizeBytes(s string) float64 {
+	fields := strings.Fields(strings.TrimLeft(s, "+-"))
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	if len(fields) > 1 {
+		switch strings.ToUpper(fields[1][:1]) {
+		case "K":
+			n *= 1 << 10
+		case "M":
+			n *= 1 << 20
+		case "G":
+			n *= 1 << 30
+		case "T":
+			n *= 1 << 40
+		}
+	}
+	return n
+}
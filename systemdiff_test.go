@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// nvdOutput is "nvd diff" output, colour codes and all.
+const nvdOutput = "<<< /nix/var/nix/profiles/system-41-link\n" +
+	">>> /nix/var/nix/profiles/system-42-link\n" +
+	"\n" +
+	"Version changes:\n" +
+	"[\x1b[33;1mU\x1b[0m.]  #1  firefox                   122.0 -> 123.0\n" +
+	"[\x1b[33;1mU\x1b[0m*]  #2  linux                     6.6.17 -> 6.6.18\n" +
+	"[D.]  #3  python3.11-requests       2.31.0 -> 2.28.2\n" +
+	"[C.]  #4  openssl                   3.0.12, 3.0.12-bin -> 3.0.13, 3.0.13-bin\n" +
+	"Added packages:\n" +
+	"[\x1b[32;1mA\x1b[0m.]  #1  htop                      3.3.0\n" +
+	"Removed packages:\n" +
+	"[R.]  #1  nano                      7.2\n" +
+	"Closure size: 1234 -> 1240 (56 paths added, 50 paths removed, delta +6, disk usage +12.3MiB).\n"
+
+// diffClosuresOutput is "nix store diff-closures" output.
+const diffClosuresOutput = "firefox: 122.0 → 123.0, +5.2 MiB\n" +
+	"htop: ∅ → 3.3.0, +400.1 KiB\n" +
+	"nano: 7.2 → ∅, -2.1 MiB\n" +
+	"glibc: 2.38-44 → 2.38-27, -8.0 KiB\n" +
+	"nixos-system-box: 24.05.20240301.3a9c1c2 → 24.05.20240302.0e7f9a1\n" +
+	"linux: +12.0 KiB\n"
+
+func TestParseSystemDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []pkgChange
+		before  int
+		after   int
+		delta   string
+		summary string
+	}{
+		{
+			name: "nvd",
+			raw:  nvdOutput,
+			want: []pkgChange{
+				{name: "firefox", kind: changeUpgraded, old: "122.0", new: "123.0"},
+				{name: "linux", kind: changeUpgraded, old: "6.6.17", new: "6.6.18"},
+				{name: "python3.11-requests", kind: changeDowngraded, old: "2.31.0", new: "2.28.2"},
+				{name: "openssl", kind: changeChanged, old: "3.0.12, 3.0.12-bin", new: "3.0.13, 3.0.13-bin"},
+				{name: "htop", kind: changeAdded, new: "3.3.0"},
+				{name: "nano", kind: changeRemoved, old: "7.2"},
+			},
+			before:  1234,
+			after:   1240,
+			delta:   "+12.3MiB",
+			summary: "2 upgraded, 1 downgraded, 1 added, 1 removed, 1 changed, closure 1234 → 1240 paths, +12.3MiB",
+		},
+		{
+			name: "diff-closures",
+			raw:  diffClosuresOutput,
+			want: []pkgChange{
+				{name: "firefox", kind: changeUpgraded, old: "122.0", new: "123.0", size: "+5.2 MiB"},
+				{name: "htop", kind: changeAdded, new: "3.3.0", size: "+400.1 KiB"},
+				{name: "nano", kind: changeRemoved, old: "7.2", size: "-2.1 MiB"},
+				{name: "glibc", kind: changeDowngraded, old: "2.38-44", new: "2.38-27", size: "-8.0 KiB"},
+				{name: "nixos-system-box", kind: changeUpgraded, old: "24.05.20240301.3a9c1c2", new: "24.05.20240302.0e7f9a1"},
+				{name: "linux", kind: changeChanged, size: "+12.0 KiB"},
+			},
+			summary: "2 upgraded, 1 downgraded, 1 added, 1 removed, 1 changed",
+		},
+		{
+			name:    "empty",
+			raw:     "",
+			summary: "no changes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := parseSystemDiff(tt.raw)
+			if !reflect.DeepEqual(d.changes, tt.want) {
+				t.Errorf("changes =\n%+v\nwant\n%+v", d.changes, tt.want)
+			}
+			if d.closureBefore != tt.before || d.closureAfter != tt.after || d.diskDelta != tt.delta {
+				t.Errorf("closure = %d -> %d, %q, want %d -> %d, %q",
+					d.closureBefore, d.closureAfter, d.diskDelta, tt.before, tt.after, tt.delta)
+			}
+			if got := d.summary(); got != tt.summary {
+				t.Errorf("summary() = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"6.6.9", "6.6.18", -1},
+		{"2.38-44", "2.38-27", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0.1", "1.0", 1},
+		{"1.0pre", "1.0rc", -1},
+		{"24.05.20240301.3a9c1c2", "24.05.20240302.0e7f9a1", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSizeBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"+5.2 MiB", 5.2 * (1 << 20)},
+		{"-400 KiB", 400 * (1 << 10)},
+		{"+1 GiB", 1 << 30},
+		{"12 B", 12},
+		{"", 0},
+		{"lots", 0},
+	}
+	for _, tt := range tests {
+		if got := sizeBytes(tt.in); got != tt.want {
+			t.Errorf("sizeBytes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
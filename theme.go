@@ -7,7 +7,9 @@ import (
 	"fyne.io/fyne/v2/theme"
 )
 
-type xinTheme struct{}
+type xinTheme struct {
+	palette *palette
+}
 
 func (*xinTheme) Font(s fyne.TextStyle) fyne.Resource {
 	if s.Monospace {
@@ -25,8 +27,16 @@ func (*xinTheme) Font(s fyne.TextStyle) fyne.Resource {
 	return resourceGoRegularTtf
 }
 
-func (*xinTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Color {
-	return theme.DefaultTheme().Color(n, v)
+func (t *xinTheme) Color(n fyne.ThemeColorName, v fyne.ThemeVariant) color.Color {
+	switch n {
+	case theme.ColorNamePrimary, theme.ColorNameSuccess:
+		return t.palette.colorFor(roleCurrent, v)
+	case theme.ColorNameWarning:
+		return t.palette.colorFor(roleBehind, v)
+	case theme.ColorNameError:
+		return t.palette.colorFor(roleReboot, v)
+	}
+	return theme.DefaultTheme().Color(n, t.palette.themeVariant(v))
 }
 
 func (*xinTheme) Icon(n fyne.ThemeIconName) fyne.Resource {
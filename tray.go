@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+)
+
+// summaryLine is the one line description of s used in the tray menu.
+func (s *Status) summaryLine() string {
+	if !s.clientEstablished {
+		return s.displayState().String()
+	}
+
+	parts := []string{s.state.String()}
+	if s.state != stateCurrent && s.behindNote != "" {
+		parts = append(parts, s.behindNote)
+	}
+	if s.report.NeedsRestart {
+		parts = append(parts, "needs reboot")
+	}
+	if s.health.state() == healthFailing {
+		parts = append(parts, s.health.String())
+	}
+	if s.updating {
+		parts = append(parts, "updating")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// menuKey changes whenever the tray menu needs rebuilding.
+func menuKey(x *xinStatus) string {
+	var b strings.Builder
+	b.WriteString(x.summaryText())
+	fmt.Fprintf(&b, "ci:%t;", x.ci != nil)
+	for _, s := range x.config.Statuses {
+		fmt.Fprintf(&b, "%s:%s;", s.PrettyName(), s.summaryLine())
+	}
+	return b.String()
+}
+
+func (x *xinStatus) hostMenu(s *Status) *fyne.Menu {
+	summary := fyne.NewMenuItem(s.summaryLine(), nil)
+	summary.Disabled = true
+
+	wake := fyne.NewMenuItem("Wake", func() {
+		x.wake(s)
+	})
+	wake.Disabled = s.clientEstablished || s.mac == nil
+
+	update := fyne.NewMenuItem("Update", func() {
+		go x.runJob(s, actionUpdate, false, x.update)
+	})
+	update.Disabled = !s.clientEstablished || s.updating
+
+	reboot := fyne.NewMenuItem("Reboot", func() {
+		x.confirmReboot(s)
+	})
+	reboot.Disabled = !s.clientEstablished
+
+	term := fyne.NewMenuItem("Open SSH terminal", func() {
+		x.openTerminal(s)
+	})
+
+	return fyne.NewMenu(s.PrettyName(),
+		summary,
+		fyne.NewMenuItemSeparator(),
+		wake,
+		update,
+		reboot,
+		term,
+	)
+}
+
+// buildTrayMenu builds the system tray menu from the configured hosts.
+func (x *xinStatus) buildTrayMenu() *fyne.Menu {
+	header := fyne.NewMenuItem(x.summaryText(), nil)
+	header.Disabled = true
+
+	items := []*fyne.MenuItem{
+		header,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Show", func() {
+			x.window.Show()
+		}),
+		fyne.NewMenuItemSeparator(),
+	}
+
+	for _, s := range x.config.Statuses {
+		item := fyne.NewMenuItem(s.PrettyName(), nil)
+		item.ChildMenu = x.hostMenu(s)
+		items = append(items, item)
+	}
+
+	// The CI host is only known once it has connected.
+	ci := x.ci
+	ciStart := fyne.NewMenuItem("Run CI", func() {
+		err := ci.RunCmd("xin ci start", x)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	ciStart.Disabled = ci == nil
+	ciUpdate := fyne.NewMenuItem("Update", func() {
+		err := ci.RunCmd("xin ci update", x)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	ciUpdate.Disabled = ci == nil
+
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		ciStart,
+		ciUpdate,
+	)
+
+	return fyne.NewMenu("xintray", items...)
+}
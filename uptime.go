@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	uptimeClock = regexp.MustCompile(`^(\d+):(\d{2})$`)
+	uptimeUnit  = regexp.MustCompile(`^(\d+)\s*(week|day|hour|hr|min|minute|sec|second)s?$`)
+)
+
+// parseUptime understands both uptime(1) styles:
+//
+//	" 10:21:32 up 3 days,  2:10,  1 user,  load average: 0.00, 0.00, 0.00"
+//	"up 1 week, 3 days, 2 hours, 10 minutes"
+func parseUptime(s string) (time.Duration, error) {
+	i := strings.Index(s, "up ")
+	if i < 0 {
+		return 0, fmt.Errorf("can't find uptime in %q", s)
+	}
+
+	var total time.Duration
+	found := false
+	for _, part := range strings.Split(s[i+3:], ",") {
+		part = strings.TrimSpace(part)
+		if strings.Contains(part, "user") || strings.HasPrefix(part, "load") {
+			break
+		}
+
+		if m := uptimeClock.FindStringSubmatch(part); m != nil {
+			h, _ := strconv.Atoi(m[1])
+			mins, _ := strconv.Atoi(m[2])
+			total += time.Duration(h)*time.Hour + time.Duration(mins)*time.Minute
+			found = true
+			continue
+		}
+
+		// "3 days  2:10" appears when uptime(1) omits the comma.
+		fields := strings.Fields(part)
+		if len(fields) == 3 && uptimeClock.MatchString(fields[2]) {
+			d, err := parseUptime("up " + fields[0] + " " + fields[1] + ", " + fields[2])
+			if err != nil {
+				return 0, err
+			}
+			total += d
+			found = true
+			continue
+		}
+
+		m := uptimeUnit.FindStringSubmatch(part)
+		if m == nil {
+			return 0, fmt.Errorf("can't parse %q in uptime %q", part, s)
+		}
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "week":
+			total += time.Duration(n) * 7 * 24 * time.Hour
+		case "day":
+			total += time.Duration(n) * 24 * time.Hour
+		case "hour", "hr":
+			total += time.Duration(n) * time.Hour
+		case "min", "minute":
+			total += time.Duration(n) * time.Minute
+		case "sec", "second":
+			total += time.Duration(n) * time.Second
+		}
+		found = true
+	}
+
+	if !found {
+		return 0, fmt.Errorf("can't parse uptime %q", s)
+	}
+	return total, nil
+}
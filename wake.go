@@ -2,54 +2,323 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
 	"net"
+	"strconv"
+	"strings"
 )
 
+const (
+	magicHeaderLen  = 6
+	magicRepeats    = 16
+	magicPacketSize = magicHeaderLen + magicRepeats*6
+)
+
+var errNotMagicPacket = errors.New("not a magic packet")
+
 type macaddr [6]byte
 
+// magicPacket is a Wake-on-LAN payload: six 0xFF bytes, the target MAC
+// repeated sixteen times and an optional four or six byte SecureOn
+// password.
 type magicPacket struct {
-	header [6]byte
-	load   [16]macaddr
+	mac      macaddr
+	password []byte
 }
 
-func sendMagicPacket(mac net.HardwareAddr) error {
-	var pkt magicPacket
-	var maca macaddr
+func newMagicPacket(mac net.HardwareAddr, password []byte) (*magicPacket, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC %q", mac)
+	}
+	switch len(password) {
+	case 0, 4, 6:
+	default:
+		return nil, fmt.Errorf("SecureOn password must be 4 or 6 bytes, not %d", len(password))
+	}
 
-	for i := range mac {
-		maca[i] = mac[i]
+	p := &magicPacket{
+		password: password,
 	}
+	copy(p.mac[:], mac)
+	return p, nil
+}
+
+func (p *magicPacket) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
 
 	// FFFFFFFFFFF
-	for i := range pkt.header {
-		pkt.header[i] = 0xFF
-	}
+	buf.Write(bytes.Repeat([]byte{0xFF}, magicHeaderLen))
 
 	// MACMACMACMACMACMAC
-	for i := range pkt.load {
-		pkt.load[i] = maca
+	for i := 0; i < magicRepeats; i++ {
+		buf.Write(p.mac[:])
 	}
 
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.BigEndian, pkt); err != nil {
+	buf.Write(p.password)
+
+	return buf.Bytes(), nil
+}
+
+func (p *magicPacket) UnmarshalBinary(data []byte) error {
+	switch len(data) {
+	case magicPacketSize, magicPacketSize + 4, magicPacketSize + 6:
+	default:
+		return fmt.Errorf("%w: unexpected length %d", errNotMagicPacket, len(data))
+	}
+
+	for _, b := range data[:magicHeaderLen] {
+		if b != 0xFF {
+			return fmt.Errorf("%w: bad header", errNotMagicPacket)
+		}
+	}
+
+	load := data[magicHeaderLen:magicPacketSize]
+	for i := 6; i < len(load); i += 6 {
+		if !bytes.Equal(load[i:i+6], load[:6]) {
+			return fmt.Errorf("%w: MAC repetitions differ", errNotMagicPacket)
+		}
+	}
+
+	copy(p.mac[:], load[:6])
+	p.password = nil
+	if extra := data[magicPacketSize:]; len(extra) > 0 {
+		p.password = append([]byte(nil), extra...)
+	}
+
+	return nil
+}
+
+func (p *magicPacket) MAC() net.HardwareAddr {
+	return net.HardwareAddr(p.mac[:])
+}
+
+// wakeOptions controls where a magic packet is sent from and to.
+type wakeOptions struct {
+	broadcast string
+	port      int
+	iface     string
+}
+
+// packetPort is the destination UDP port, defaulting to echo.
+func (o wakeOptions) packetPort() int {
+	if o.port == 0 {
+		return 7
+	}
+	return o.port
+}
+
+// relayTarget is the broadcast address a relay host should send to.
+func (o wakeOptions) relayTarget() string {
+	if o.broadcast == "" {
+		return "255.255.255.255"
+	}
+	return o.broadcast
+}
+
+// parseSecureOn accepts a SecureOn password either as six bytes in MAC
+// notation or four bytes in dotted-quad notation.
+func parseSecureOn(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if hw, err := net.ParseMAC(s); err == nil && len(hw) == 6 {
+		return hw, nil
+	}
+	if ip := net.ParseIP(s).To4(); ip != nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("invalid SecureOn password %q", s)
+}
+
+// interfaceAddrs returns the first IPv4 address of the named interface and
+// the directed broadcast address of its subnet.
+func interfaceAddrs(name string) (net.IP, net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ip == nil {
+			continue
+		}
+		mask := ipnet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range ip {
+			bcast[i] = ip[i] | ^mask[i]
+		}
+		return ip, bcast, nil
+	}
+	return nil, nil, fmt.Errorf("no IPv4 address on %s", name)
+}
+
+// wakeSender delivers an encoded magic packet.
+type wakeSender interface {
+	sendWake(pkt *magicPacket, opts wakeOptions) error
+}
+
+// udpSender broadcasts the packet from this machine.
+type udpSender struct{}
+
+func (udpSender) sendWake(pkt *magicPacket, opts wakeOptions) error {
+	data, err := pkt.MarshalBinary()
+	if err != nil {
 		return err
 	}
 
-	broadcastAddr, err := net.ResolveUDPAddr("udp", "255.255.255.255:7")
+	bcast := opts.broadcast
+	var local *net.UDPAddr
+	if opts.iface != "" {
+		ip, ifBcast, err := interfaceAddrs(opts.iface)
+		if err != nil {
+			return err
+		}
+		local = &net.UDPAddr{IP: ip}
+		if bcast == "" {
+			bcast = ifBcast.String()
+		}
+	}
+	if bcast == "" {
+		bcast = "255.255.255.255"
+	}
+
+	broadcastAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(bcast, strconv.Itoa(opts.packetPort())))
 	if err != nil {
 		return err
 	}
 
-	conn, err := net.DialUDP("udp", nil, broadcastAddr)
+	conn, err := net.DialUDP("udp", local, broadcastAddr)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	_, err = conn.Write(buf.Bytes())
+	_, err = conn.Write(data)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultWakeRelayCmd reads the magic packet from stdin and broadcasts it
+// from the relay host.
+const defaultWakeRelayCmd = "socat -u STDIN UDP-DATAGRAM:{broadcast}:{port},broadcast"
+
+// relaySender has another host send the packet on our behalf, for when the
+// target isn't on a network we can broadcast to.
+type relaySender struct {
+	relay relayHost
+	cmd   string
+}
+
+// relayHost is what relaySender needs from the relaying host.
+type relayHost interface {
+	Exec(cmd string, stdin []byte) ([]byte, error)
+	PrettyName() string
+}
+
+func (r relaySender) sendWake(pkt *magicPacket, opts wakeOptions) error {
+	data, err := pkt.MarshalBinary()
 	if err != nil {
 		return err
 	}
+
+	tmpl := r.cmd
+	if tmpl == "" {
+		tmpl = defaultWakeRelayCmd
+	}
+	cmd := strings.NewReplacer(
+		"{broadcast}", opts.relayTarget(),
+		"{port}", strconv.Itoa(opts.packetPort()),
+		"{mac}", pkt.MAC().String(),
+	).Replace(tmpl)
+
+	out, err := r.relay.Exec(cmd, data)
+	if err != nil {
+		return fmt.Errorf("relay %s: %w: %s", r.relay.PrettyName(), err, strings.TrimSpace(string(out)))
+	}
+
 	return nil
 }
+
+// wakeListener receives and decodes magic packets. It lets us check what
+// we're sending without needing a machine to wake.
+type wakeListener struct {
+	conn *net.UDPConn
+}
+
+func listenWake(addr string) (*wakeListener, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &wakeListener{conn: conn}, nil
+}
+
+func (l *wakeListener) Addr() *net.UDPAddr {
+	return l.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Receive waits for the next datagram and decodes it.
+func (l *wakeListener) Receive() (*magicPacket, *net.UDPAddr, error) {
+	buf := make([]byte, 1500)
+	n, from, err := l.conn.ReadFromUDP(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkt := &magicPacket{}
+	if err := pkt.UnmarshalBinary(buf[:n]); err != nil {
+		return nil, from, err
+	}
+	return pkt, from, nil
+}
+
+func (l *wakeListener) Close() error {
+	return l.conn.Close()
+}
+
+// runWakeListener logs every magic packet that arrives on addr. It's for
+// checking what a wake relay actually puts on a remote network, which is
+// the one thing we can't see from here.
+func runWakeListener(addr string) error {
+	l, err := listenWake(addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	log.Printf("listening for magic packets on %s", l.Addr())
+	for {
+		pkt, from, err := l.Receive()
+		if errors.Is(err, errNotMagicPacket) {
+			log.Printf("%s: %s", from, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(pkt.password) > 0 {
+			log.Printf("%s: wake %s (SecureOn % x)", from, pkt.MAC(), pkt.password)
+		} else {
+			log.Printf("%s: wake %s", from, pkt.MAC())
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+var testMAC = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+func wantPacket(mac net.HardwareAddr, password []byte) []byte {
+	want := bytes.Repeat([]byte{0xFF}, 6)
+	for i := 0; i < 16; i++ {
+		want = append(want, mac...)
+	}
+	return append(want, password...)
+}
+
+func TestMagicPacketRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		password []byte
+	}{
+		{"no password", nil},
+		{"4 byte password", []byte{192, 168, 1, 1}},
+		{"6 byte password", []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := newMagicPacket(testMAC, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := pkt.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := wantPacket(testMAC, tt.password); !bytes.Equal(data, want) {
+				t.Fatalf("MarshalBinary() = % x, want % x", data, want)
+			}
+
+			got := &magicPacket{}
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.MAC(), testMAC) {
+				t.Errorf("MAC = %s, want %s", got.MAC(), testMAC)
+			}
+			if !bytes.Equal(got.password, tt.password) {
+				t.Errorf("password = % x, want % x", got.password, tt.password)
+			}
+		})
+	}
+}
+
+func TestNewMagicPacketInvalid(t *testing.T) {
+	if _, err := newMagicPacket(testMAC[:4], nil); err == nil {
+		t.Error("accepted a 4 byte MAC")
+	}
+	if _, err := newMagicPacket(testMAC, []byte{1, 2, 3}); err == nil {
+		t.Error("accepted a 3 byte password")
+	}
+}
+
+func TestUnmarshalMagicPacketInvalid(t *testing.T) {
+	good := wantPacket(testMAC, nil)
+
+	badHeader := append([]byte(nil), good...)
+	badHeader[0] = 0x00
+
+	badRepeat := append([]byte(nil), good...)
+	badRepeat[len(badRepeat)-1] ^= 0xFF
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short", good[:len(good)-1]},
+		{"odd password length", append(append([]byte(nil), good...), 1, 2, 3)},
+		{"bad header", badHeader},
+		{"mismatched MAC repeat", badRepeat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&magicPacket{}).UnmarshalBinary(tt.data)
+			if !errors.Is(err, errNotMagicPacket) {
+				t.Errorf("UnmarshalBinary() = %v, want %v", err, errNotMagicPacket)
+			}
+		})
+	}
+}
+
+// testPacketWithPassword is the magic packet for testMAC with the SecureOn
+// password de:ad:be:ef, written out by hand.
+var testPacketWithPassword = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0xde, 0xad, 0xbe, 0xef,
+}
+
+func TestUDPSenderDelivers(t *testing.T) {
+	l, err := listenWake("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	pkt, err := newMagicPacket(testMAC, []byte{0xde, 0xad, 0xbe, 0xef})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := wakeOptions{
+		broadcast: "127.0.0.1",
+		port:      l.Addr().Port,
+	}
+
+	type received struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan received, 1)
+	go func() {
+		buf := make([]byte, 1500)
+		n, _, err := l.conn.ReadFromUDP(buf)
+		ch <- received{buf[:n], err}
+	}()
+
+	if err := (udpSender{}).sendWake(pkt, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if !bytes.Equal(r.data, testPacketWithPassword) {
+			t.Errorf("received % x, want % x", r.data, testPacketWithPassword)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing received on port %d", opts.port)
+	}
+}
+
+// fakeRelay records what a relaySender asks it to run.
+type fakeRelay struct {
+	cmd   string
+	stdin []byte
+	out   []byte
+	err   error
+}
+
+func (f *fakeRelay) Exec(cmd string, stdin []byte) ([]byte, error) {
+	f.cmd = cmd
+	f.stdin = append([]byte(nil), stdin...)
+	return f.out, f.err
+}
+
+func (f *fakeRelay) PrettyName() string { return "relay" }
+
+func TestRelaySender(t *testing.T) {
+	pkt, err := newMagicPacket(testMAC, []byte{0xde, 0xad, 0xbe, 0xef})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tmpl string
+		opts wakeOptions
+		want string
+	}{
+		{
+			name: "default command",
+			want: "socat -u STDIN UDP-DATAGRAM:255.255.255.255:7,broadcast",
+		},
+		{
+			name: "default command with options",
+			opts: wakeOptions{broadcast: "192.168.1.255", port: 9},
+			want: "socat -u STDIN UDP-DATAGRAM:192.168.1.255:9,broadcast",
+		},
+		{
+			name: "custom command",
+			tmpl: "wakeonlan -i {broadcast} -p {port} {mac}",
+			opts: wakeOptions{broadcast: "10.0.0.255"},
+			want: "wakeonlan -i 10.0.0.255 -p 7 00:11:22:33:44:55",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := &fakeRelay{}
+			if err := (relaySender{relay: relay, cmd: tt.tmpl}).sendWake(pkt, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if relay.cmd != tt.want {
+				t.Errorf("ran %q, want %q", relay.cmd, tt.want)
+			}
+			if !bytes.Equal(relay.stdin, testPacketWithPassword) {
+				t.Errorf("stdin = % x, want % x", relay.stdin, testPacketWithPassword)
+			}
+		})
+	}
+}
+
+func TestRelaySenderError(t *testing.T) {
+	pkt, err := newMagicPacket(testMAC, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := &fakeRelay{
+		out: []byte("socat: command not found\n"),
+		err: errors.New("Process exited with status 127"),
+	}
+
+	err = (relaySender{relay: relay}).sendWake(pkt, wakeOptions{})
+	if !errors.Is(err, relay.err) {
+		t.Fatalf("sendWake() = %v, want it to wrap %v", err, relay.err)
+	}
+	want := "relay relay: Process exited with status 127: socat: command not found"
+	if err.Error() != want {
+		t.Errorf("sendWake() = %q, want %q", err, want)
+	}
+}
+
+func TestWakeOptionsDefaults(t *testing.T) {
+	var opts wakeOptions
+	if got := opts.packetPort(); got != 7 {
+		t.Errorf("packetPort() = %d, want 7", got)
+	}
+	if got := opts.relayTarget(); got != "255.255.255.255" {
+		t.Errorf("relayTarget() = %q, want 255.255.255.255", got)
+	}
+}